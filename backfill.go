@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/rocket-pool/rocketpool-go/settings/protocol"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Backfill file formats
+const (
+	backfillFormatCsv   string = "csv"
+	backfillFormatJsonl string = "jsonl"
+)
+
+// Backfill task
+type backfill struct {
+	c        *cli.Context
+	log      log.ColorLogger
+	errLog   log.ColorLogger
+	ec       rocketpool.ExecutionClient
+	rp       *rocketpool.RocketPool
+	price    *submitRplPrice
+	balances *submitNetworkBalances
+}
+
+// A single point in the backfilled time series
+type backfillRow struct {
+	Block                 uint64  `json:"block"`
+	BeaconSlot            uint64  `json:"beaconSlot"`
+	Time                  string  `json:"time"`
+	RplPrice              string  `json:"rplPrice"`
+	DepositPool           string  `json:"depositPool"`
+	MinipoolsTotal        string  `json:"minipoolsTotal"`
+	MinipoolsStaking      string  `json:"minipoolsStaking"`
+	DistributorShareTotal string  `json:"distributorShareTotal"`
+	SmoothingPoolShare    string  `json:"smoothingPoolShare"`
+	RETHContract          string  `json:"rethContract"`
+	RETHSupply            string  `json:"rethSupply"`
+	NodeCreditBalance     string  `json:"nodeCreditBalance"`
+	TotalEth              string  `json:"totalEth"`
+	RETHRatio             float64 `json:"rethRatio"`
+}
+
+// CSV column names, in the same order as backfillRow.csvRecord()
+var backfillCsvHeader = []string{
+	"block",
+	"beaconSlot",
+	"time",
+	"rplPrice",
+	"depositPool",
+	"minipoolsTotal",
+	"minipoolsStaking",
+	"distributorShareTotal",
+	"smoothingPoolShare",
+	"rethContract",
+	"rethSupply",
+	"nodeCreditBalance",
+	"totalEth",
+	"rethRatio",
+}
+
+// A completed row and its position in the list of points
+type backfillResult struct {
+	index int
+	row   backfillRow
+}
+
+// Create backfill task
+func newBackfill(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*backfill, error) {
+
+	ec, bc, rp, cfg, mgr, err := initialize(c, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing RP artifacts: %w", err)
+	}
+
+	price, balances := newDutyTasks(c, logger, errorLogger, ec, bc, rp, cfg, mgr)
+
+	// Return task
+	return &backfill{
+		c:        c,
+		log:      logger,
+		errLog:   errorLogger,
+		ec:       ec,
+		rp:       rp,
+		price:    price,
+		balances: balances,
+	}, nil
+
+}
+
+// Run the backfill
+func (t *backfill) run() error {
+
+	// Get the settings
+	outputPath := t.c.String("output-file")
+	if outputPath == "" {
+		return fmt.Errorf("output-file must be provided")
+	}
+	format := t.c.String("format")
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(outputPath), ".")
+	}
+	if format != backfillFormatCsv && format != backfillFormatJsonl {
+		return fmt.Errorf("unknown backfill format [%s], must be '%s' or '%s'", format, backfillFormatCsv, backfillFormatJsonl)
+	}
+	workers := t.c.Int("workers")
+	if workers < 1 {
+		return fmt.Errorf("workers must be at least 1")
+	}
+
+	// Get the points to run
+	fromBlock, toBlock, err := t.getBlockRange()
+	if err != nil {
+		return err
+	}
+	points, err := t.getPoints(fromBlock, toBlock)
+	if err != nil {
+		return err
+	}
+
+	// Open the output file and skip everything that's already been written
+	file, lastBlock, err := openBackfillFile(outputPath, format)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if lastBlock != nil {
+		skipped := 0
+		for skipped < len(points) && points[skipped] <= *lastBlock {
+			skipped++
+		}
+		points = points[skipped:]
+		t.log.Printlnf("Resuming after block %d from the existing output file, skipping %d points.", *lastBlock, skipped)
+	}
+	if len(points) == 0 {
+		t.log.Println("Nothing left to backfill.")
+		return nil
+	}
+	t.log.Printlnf("Backfilling %d points between blocks %d and %d with %d workers...", len(points), points[0], points[len(points)-1], workers)
+
+	// Write the CSV header for new files
+	writer := bufio.NewWriter(file)
+	csvWriter := csv.NewWriter(writer)
+	if format == backfillFormatCsv && lastBlock == nil {
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("error checking output file: %w", err)
+		}
+		if info.Size() == 0 {
+			csvWriter.Write(backfillCsvHeader)
+			csvWriter.Flush()
+			if err := writer.Flush(); err != nil {
+				return fmt.Errorf("error writing to output file: %w", err)
+			}
+		}
+	}
+
+	// Run the points with a bounded worker pool. If the writer fails, returning cancels the workers so none of them are
+	// left blocked sending a result nobody will read.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan backfillResult, workers)
+	var dispatchErr error
+	go func() {
+		wg, ctx := errgroup.WithContext(ctx)
+		wg.SetLimit(workers)
+		for i, block := range points {
+			i := i
+			block := block
+			wg.Go(func() error {
+				if ctx.Err() != nil {
+					return nil
+				}
+				row, err := t.getRow(block)
+				if err != nil {
+					return fmt.Errorf("error backfilling block %d: %w", block, err)
+				}
+				select {
+				case results <- backfillResult{index: i, row: row}:
+				case <-ctx.Done():
+				}
+				return nil
+			})
+		}
+		dispatchErr = wg.Wait()
+		close(results)
+	}()
+
+	// Write the rows in order as they come in so the file can always be resumed
+	pending := map[int]backfillRow{}
+	next := 0
+	for result := range results {
+		pending[result.index] = result.row
+		for {
+			row, exists := pending[next]
+			if !exists {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if format == backfillFormatCsv {
+				csvWriter.Write(row.csvRecord())
+				csvWriter.Flush()
+				err = csvWriter.Error()
+			} else {
+				var bytes []byte
+				bytes, err = json.Marshal(row)
+				if err == nil {
+					_, err = writer.Write(append(bytes, '\n'))
+				}
+			}
+			if err == nil {
+				err = writer.Flush()
+			}
+			if err != nil {
+				return fmt.Errorf("error writing to output file: %w", err)
+			}
+			t.log.Printlnf("Wrote block %d (%d/%d).", row.Block, next, len(points))
+		}
+	}
+	if dispatchErr != nil {
+		return dispatchErr
+	}
+
+	t.log.Println("Backfill complete.")
+	return nil
+
+}
+
+// Get the block range to backfill from the block or date flags
+func (t *backfill) getBlockRange() (uint64, uint64, error) {
+
+	var fromBlock uint64
+	var toBlock uint64
+	var err error
+
+	switch {
+	case t.c.IsSet("from-block") && t.c.IsSet("from-date"):
+		return 0, 0, fmt.Errorf("from-block and from-date cannot both be provided")
+	case t.c.IsSet("from-block"):
+		fromBlock = t.c.Uint64("from-block")
+	case t.c.IsSet("from-date"):
+		fromBlock, err = t.getBlockForDate(t.c.String("from-date"))
+		if err != nil {
+			return 0, 0, err
+		}
+	default:
+		return 0, 0, fmt.Errorf("either from-block or from-date must be provided")
+	}
+
+	switch {
+	case t.c.IsSet("to-block") && t.c.IsSet("to-date"):
+		return 0, 0, fmt.Errorf("to-block and to-date cannot both be provided")
+	case t.c.IsSet("to-block"):
+		toBlock = t.c.Uint64("to-block")
+	case t.c.IsSet("to-date"):
+		toBlock, err = t.getBlockForDate(t.c.String("to-date"))
+		if err != nil {
+			return 0, 0, err
+		}
+		if toBlock == 0 {
+			return 0, 0, fmt.Errorf("to-date is before the first block")
+		}
+		toBlock-- // The end date is exclusive
+	default:
+		header, err := t.ec.HeaderByNumber(context.Background(), nil)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error getting latest EL block: %w", err)
+		}
+		toBlock = header.Number.Uint64()
+	}
+
+	if fromBlock > toBlock {
+		return 0, 0, fmt.Errorf("start block %d is after end block %d", fromBlock, toBlock)
+	}
+	return fromBlock, toBlock, nil
+
+}
+
+// Get the blocks to run the calculations for
+func (t *backfill) getPoints(fromBlock uint64, toBlock uint64) ([]uint64, error) {
+
+	step := t.c.Uint64("step")
+	first := fromBlock
+	if t.c.Bool("step-interval") {
+		if t.c.IsSet("step") {
+			return nil, fmt.Errorf("step and step-interval cannot both be provided")
+		}
+
+		// Align the points with the balance reporting checkpoints
+		frequency, err := protocol.GetSubmitBalancesFrequency(t.rp, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error getting balance submission frequency: %w", err)
+		}
+		if frequency == 0 {
+			return nil, fmt.Errorf("balance submission frequency is 0")
+		}
+		step = frequency
+		first = (fromBlock + frequency - 1) / frequency * frequency
+		t.log.Printlnf("Using the balance reporting interval of %d blocks.", frequency)
+	}
+	if step == 0 {
+		return nil, fmt.Errorf("either a non-zero step or step-interval must be provided")
+	}
+
+	points := []uint64{}
+	for block := first; block <= toBlock; block += step {
+		points = append(points, block)
+	}
+	return points, nil
+
+}
+
+// Get the first EL block at or after the provided date
+func (t *backfill) getBlockForDate(date string) (uint64, error) {
+
+	targetTime, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		targetTime, err = time.Parse("2006-01-02", date)
+		if err != nil {
+			return 0, fmt.Errorf("invalid date [%s], must be YYYY-MM-DD or RFC3339", date)
+		}
+	}
+
+	// Binary search for the block
+	header, err := t.ec.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("error getting latest EL block: %w", err)
+	}
+	low := uint64(0)
+	high := header.Number.Uint64() + 1
+	for low < high {
+		mid := (low + high) / 2
+		header, err := t.ec.HeaderByNumber(context.Background(), big.NewInt(0).SetUint64(mid))
+		if err != nil {
+			return 0, fmt.Errorf("error getting header for EL block %d: %w", mid, err)
+		}
+		if time.Unix(int64(header.Time), 0).Before(targetTime) {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+
+	t.log.Printlnf("Date %s corresponds to EL block %d.", date, low)
+	return low, nil
+
+}
+
+// Run the price and balance calculations for a single block
+func (t *backfill) getRow(block uint64) (backfillRow, error) {
+
+	header, err := t.ec.HeaderByNumber(context.Background(), big.NewInt(0).SetUint64(block))
+	if err != nil {
+		return backfillRow{}, fmt.Errorf("error getting header: %w", err)
+	}
+
+	rplPrice, err := t.price.getRplTwap(block)
+	if err != nil {
+		return backfillRow{}, err
+	}
+	balances, slotNumber, err := t.balances.getNetworkBalancesForBlock(block)
+	if err != nil {
+		return backfillRow{}, err
+	}
+
+	totalEth := balances.getTotalEth()
+	return backfillRow{
+		Block:                 block,
+		BeaconSlot:            slotNumber,
+		Time:                  time.Unix(int64(header.Time), 0).UTC().Format(time.RFC3339),
+		RplPrice:              rplPrice.String(),
+		DepositPool:           balances.DepositPool.String(),
+		MinipoolsTotal:        balances.MinipoolsTotal.String(),
+		MinipoolsStaking:      balances.MinipoolsStaking.String(),
+		DistributorShareTotal: balances.DistributorShareTotal.String(),
+		SmoothingPoolShare:    balances.SmoothingPoolShare.String(),
+		RETHContract:          balances.RETHContract.String(),
+		RETHSupply:            balances.RETHSupply.String(),
+		NodeCreditBalance:     balances.NodeCreditBalance.String(),
+		TotalEth:              totalEth.String(),
+		RETHRatio:             balances.getRETHRatio(),
+	}, nil
+
+}
+
+// Get the row as a CSV record
+func (r *backfillRow) csvRecord() []string {
+	return []string{
+		strconv.FormatUint(r.Block, 10),
+		strconv.FormatUint(r.BeaconSlot, 10),
+		r.Time,
+		r.RplPrice,
+		r.DepositPool,
+		r.MinipoolsTotal,
+		r.MinipoolsStaking,
+		r.DistributorShareTotal,
+		r.SmoothingPoolShare,
+		r.RETHContract,
+		r.RETHSupply,
+		r.NodeCreditBalance,
+		r.TotalEth,
+		strconv.FormatFloat(r.RETHRatio, 'f', -1, 64),
+	}
+}
+
+// Open the backfill output file for appending and get the block of the last completed row in it, if there is one.
+// A partially written trailing row is truncated.
+func openBackfillFile(path string, format string) (*os.File, *uint64, error) {
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening output file: %w", err)
+	}
+	contents, err := io.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("error reading output file: %w", err)
+	}
+
+	// Drop anything after the last complete line
+	completeLength := strings.LastIndex(string(contents), "\n") + 1
+	if completeLength != len(contents) {
+		err = file.Truncate(int64(completeLength))
+		if err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("error truncating partial row in output file: %w", err)
+		}
+	}
+	_, err = file.Seek(int64(completeLength), io.SeekStart)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("error seeking to the end of the output file: %w", err)
+	}
+
+	// Get the block of the last row
+	lines := strings.Split(strings.TrimSpace(string(contents[:completeLength])), "\n")
+	lastLine := lines[len(lines)-1]
+	if lastLine == "" || (format == backfillFormatCsv && len(lines) == 1) {
+		return file, nil, nil
+	}
+	var lastBlock uint64
+	if format == backfillFormatCsv {
+		lastBlock, err = strconv.ParseUint(strings.SplitN(lastLine, ",", 2)[0], 10, 64)
+	} else {
+		row := backfillRow{}
+		err = json.Unmarshal([]byte(lastLine), &row)
+		lastBlock = row.Block
+	}
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("error parsing the last row of the output file: %w", err)
+	}
+	return file, &lastBlock, nil
+
+}
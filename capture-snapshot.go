@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	"github.com/rocket-pool/smartnode/shared/services/config"
+	"github.com/rocket-pool/smartnode/shared/services/state"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Capture snapshot task
+type captureSnapshot struct {
+	c        *cli.Context
+	log      log.ColorLogger
+	errLog   log.ColorLogger
+	cfg      *config.RocketPoolConfig
+	ec       rocketpool.ExecutionClient
+	rp       *rocketpool.RocketPool
+	bc       beacon.Client
+	mgr      *stateManager
+	price    *submitRplPrice
+	balances *submitNetworkBalances
+}
+
+// Create capture snapshot task
+func newCaptureSnapshot(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*captureSnapshot, error) {
+
+	if !c.IsSet("target-block") {
+		return nil, fmt.Errorf("target-block must be provided")
+	}
+	ec, bc, rp, cfg, mgr, err := initialize(c, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing RP artifacts: %w", err)
+	}
+
+	price, balances := newDutyTasks(c, logger, errorLogger, ec, bc, rp, cfg, mgr)
+
+	// Return task
+	return &captureSnapshot{
+		c:        c,
+		log:      logger,
+		errLog:   errorLogger,
+		cfg:      cfg,
+		ec:       ec,
+		rp:       rp,
+		bc:       bc,
+		mgr:      mgr,
+		price:    price,
+		balances: balances,
+	}, nil
+
+}
+
+// Capture everything the price and balance duties need for the target block into a snapshot file
+func (t *captureSnapshot) run() error {
+
+	targetState, err := getTargetState(t.c, t.ec, t.mgr, t.log)
+	if err != nil {
+		return err
+	}
+	snapshot := &dutySnapshot{
+		Version:         dutySnapshotVersion,
+		Network:         t.mgr.Network,
+		ChainID:         t.mgr.ChainID,
+		TargetBlock:     t.c.Uint64("target-block"),
+		ReportableBlock: t.c.Bool("reportable-block"),
+		CapturedAt:      time.Now().UTC(),
+		BeaconConfig:    t.mgr.BeaconConfig,
+		TargetState:     newStateSnapshotBody(targetState),
+	}
+
+	// Capture the TWAP pool observation for the price duty
+	if !targetState.NetworkDetails.SubmitPricesEnabled {
+		t.log.Println("Price submissions are disabled, so the snapshot won't include the RPL price.")
+	} else {
+		blockNumber := getDutyBlock(t.c, t.log, targetState.ElBlockNumber, targetState.NetworkDetails.LatestReportablePricesBlock)
+		response, poolAddress, err := t.price.getRplTwapObservation(blockNumber)
+		if err != nil {
+			return err
+		}
+		snapshot.Prices = &priceSnapshot{
+			Block:           blockNumber,
+			PoolAddress:     poolAddress,
+			Interval:        twapNumberOfSeconds,
+			TickCumulatives: response.TickCumulatives,
+		}
+		t.log.Printlnf("Captured the TWAP pool observation for block %d.", blockNumber)
+	}
+
+	// Capture the state and header for the balances duty
+	if !targetState.NetworkDetails.SubmitBalancesEnabled {
+		t.log.Println("Balance submissions are disabled, so the snapshot won't include network balances.")
+	} else {
+		blockNumber := getDutyBlock(t.c, t.log, targetState.ElBlockNumber, targetState.NetworkDetails.LatestReportableBalancesBlock.Uint64())
+		balancesSnapshot, err := t.getBalancesSnapshot(blockNumber, targetState)
+		if err != nil {
+			return err
+		}
+		snapshot.Balances = balancesSnapshot
+		t.log.Printlnf("Captured the network state for balances block %d, slot %d.", blockNumber, balancesSnapshot.Slot)
+	}
+
+	// Write the file
+	path := t.c.String("file")
+	if path == "" {
+		path = fmt.Sprintf("snapshot-%s-%d%s", snapshot.Network, snapshot.TargetBlock, stateSnapshotExtension)
+	}
+	err = writeDutySnapshot(path, snapshot)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("error checking %s: %w", path, err)
+	}
+	t.log.Printlnf("Wrote snapshot of block %d to %s (%.1f MiB).", snapshot.TargetBlock, path, float64(info.Size())/(1024*1024))
+	return nil
+
+}
+
+// Capture the inputs of the balances duty for a block
+func (t *captureSnapshot) getBalancesSnapshot(blockNumber uint64, targetState *state.NetworkState) (*balancesSnapshot, error) {
+
+	blockNumberBig := big.NewInt(0).SetUint64(blockNumber)
+	header, err := t.ec.HeaderByNumber(context.Background(), blockNumberBig)
+	if err != nil {
+		return nil, fmt.Errorf("error getting header for EL block %d: %w", blockNumber, err)
+	}
+	headerRlp, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding header for EL block %d: %w", blockNumber, err)
+	}
+	slotNumber := getBeaconSlotForBlock(header, t.mgr.BeaconConfig)
+	isAtlasDeployed, err := state.IsAtlasDeployed(t.rp, &bind.CallOpts{BlockNumber: blockNumberBig})
+	if err != nil {
+		return nil, fmt.Errorf("error checking if Atlas is deployed at EL block %d: %w", blockNumber, err)
+	}
+	snapshot := &balancesSnapshot{
+		Block:           blockNumber,
+		HeaderRlp:       headerRlp,
+		Slot:            slotNumber,
+		IsAtlasDeployed: isAtlasDeployed,
+	}
+
+	// Reuse the target state if it's for the same slot
+	client := t.rp
+	balancesState := targetState
+	if slotNumber != targetState.BeaconSlotNumber {
+		client, balancesState, err = t.balances.getStateForBalances(blockNumberBig, slotNumber)
+		if err != nil {
+			return nil, err
+		}
+		body := newStateSnapshotBody(balancesState)
+		snapshot.State = &body
+	}
+
+	// Approximate the Smoothing Pool share now, since that can't be done offline
+	blockTime := time.Unix(int64(header.Time), 0)
+	balances, err := t.balances.getNetworkBalancesFromState(client, balancesState, header, slotNumber, blockTime, isAtlasDeployed)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.SmoothingPoolShare = balances.SmoothingPoolShare
+	return snapshot, nil
+
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	"github.com/rocket-pool/smartnode/shared/services/state"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Settings, matching the watchtower
+const (
+	bondReductionBalanceBuffer    uint64 = 1000000 // 0.001 ETH
+	bondReductionBalanceThreshold uint64 = 32000000000 - bondReductionBalanceBuffer
+)
+
+// Check bond reductions task
+type checkBondReductions struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	ec           rocketpool.ExecutionClient
+	mgr          *stateManager
+	outputFormat string
+}
+
+// A minipool with a pending bond reduction
+type pendingBondReduction struct {
+	Address          common.Address        `json:"address"`
+	NodeAddress      common.Address        `json:"nodeAddress"`
+	RequestTime      time.Time             `json:"requestTime"`
+	NewBondValue     string                `json:"newBondValue"`
+	ValidatorExists  bool                  `json:"validatorExists"`
+	ValidatorStatus  beacon.ValidatorState `json:"validatorStatus,omitempty"`
+	ValidatorBalance uint64                `json:"validatorBalance"`
+	Cancel           bool                  `json:"cancel"`
+	Reason           string                `json:"reason,omitempty"`
+}
+
+// Machine-readable bond reduction check report
+type checkBondReductionsOutput struct {
+	ElBlock           uint64                 `json:"elBlock"`
+	BeaconSlot        uint64                 `json:"beaconSlot"`
+	AlreadyCancelled  int                    `json:"alreadyCancelled"`
+	PendingReductions []pendingBondReduction `json:"pendingReductions"`
+}
+
+// Create check bond reductions task
+func newCheckBondReductions(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*checkBondReductions, error) {
+
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ec, _, _, _, mgr, err := initialize(c, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing RP artifacts: %w", err)
+	}
+
+	// Return task
+	return &checkBondReductions{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		ec:           ec,
+		mgr:          mgr,
+		outputFormat: outputFormat,
+	}, nil
+
+}
+
+// Check for bond reductions to cancel
+func (t *checkBondReductions) run() error {
+
+	state, err := getTargetState(t.c, t.ec, t.mgr, t.log)
+	if err != nil {
+		return err
+	}
+
+	output := checkBondReductionsOutput{
+		ElBlock:           state.ElBlockNumber,
+		BeaconSlot:        state.BeaconSlotNumber,
+		PendingReductions: []pendingBondReduction{},
+	}
+	if !state.IsAtlasDeployed {
+		t.log.Printlnf("Atlas isn't deployed as of EL block %d, so there are no bond reductions to check.", state.ElBlockNumber)
+	} else {
+		output.PendingReductions, output.AlreadyCancelled, err = getPendingBondReductions(state)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Print the results
+	t.log.Printlnf("Found %d pending bond reductions at EL block %d, CL slot %d (%d already cancelled).", len(output.PendingReductions), state.ElBlockNumber, state.BeaconSlotNumber, output.AlreadyCancelled)
+	cancelCount := 0
+	for _, reduction := range output.PendingReductions {
+		if reduction.Cancel {
+			cancelCount++
+			t.errLog.Printlnf("Minipool %s (node %s) would have its bond reduction cancelled: %s", reduction.Address.Hex(), reduction.NodeAddress.Hex(), reduction.Reason)
+		} else {
+			t.log.Printlnf("Minipool %s (node %s) requested a bond reduction at %s: %s", reduction.Address.Hex(), reduction.NodeAddress.Hex(), reduction.RequestTime.Format(time.RFC3339), reduction.Reason)
+		}
+	}
+	if cancelCount == 0 {
+		t.log.Println("No bond reductions would be cancelled.")
+	} else {
+		t.log.Printlnf("%d bond reduction(s) would be cancelled.", cancelCount)
+	}
+
+	// Print the machine-readable report
+	if t.outputFormat == outputFormatJson {
+		return printJson(output)
+	}
+	return nil
+
+}
+
+// Get the minipools with a pending bond reduction, and whether the watchtower would cancel each one
+func getPendingBondReductions(state *state.NetworkState) ([]pendingBondReduction, int, error) {
+
+	reductions := []pendingBondReduction{}
+	alreadyCancelled := 0
+	zero := big.NewInt(0)
+	for _, mpd := range state.MinipoolDetails {
+		if mpd.ReduceBondTime.Cmp(zero) != 1 {
+			continue
+		}
+		if mpd.ReduceBondCancelled {
+			alreadyCancelled++
+			continue
+		}
+
+		reduction := pendingBondReduction{
+			Address:      mpd.MinipoolAddress,
+			NodeAddress:  mpd.NodeAddress,
+			RequestTime:  time.Unix(mpd.ReduceBondTime.Int64(), 0).UTC(),
+			NewBondValue: mpd.ReduceBondValue.String(),
+		}
+
+		// Check the validator's status and balance
+		validator := state.ValidatorDetails[mpd.Pubkey]
+		reduction.ValidatorExists = validator.Exists
+		if !validator.Exists {
+			reduction.Reason = "validator isn't on the Beacon Chain yet"
+			reductions = append(reductions, reduction)
+			continue
+		}
+		reduction.ValidatorStatus = validator.Status
+		reduction.ValidatorBalance = validator.Balance
+		switch validator.Status {
+		case beacon.ValidatorState_PendingInitialized,
+			beacon.ValidatorState_PendingQueued:
+			reduction.Reason = "validator isn't live yet"
+		case beacon.ValidatorState_ActiveOngoing:
+			if validator.Balance < bondReductionBalanceThreshold {
+				reduction.Cancel = true
+				reduction.Reason = fmt.Sprintf("minipool balance is %d gwei (below the threshold of %d gwei)", validator.Balance, bondReductionBalanceThreshold)
+			} else {
+				reduction.Reason = fmt.Sprintf("minipool balance is %d gwei", validator.Balance)
+			}
+		case beacon.ValidatorState_ActiveExiting,
+			beacon.ValidatorState_ActiveSlashed,
+			beacon.ValidatorState_ExitedUnslashed,
+			beacon.ValidatorState_ExitedSlashed,
+			beacon.ValidatorState_WithdrawalPossible,
+			beacon.ValidatorState_WithdrawalDone:
+			reduction.Cancel = true
+			reduction.Reason = fmt.Sprintf("minipool is already slashed, exiting, or exited (%s)", validator.Status)
+		default:
+			return nil, 0, fmt.Errorf("unknown validator state for minipool %s: %v", mpd.MinipoolAddress.Hex(), validator.Status)
+		}
+		reductions = append(reductions, reduction)
+	}
+
+	return reductions, alreadyCancelled, nil
+
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rocket-pool/rocketpool-go/node"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	rpstate "github.com/rocket-pool/rocketpool-go/utils/state"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	"github.com/rocket-pool/smartnode/shared/services/config"
+	"github.com/rocket-pool/smartnode/shared/services/state"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Check penalties task
+type checkPenalties struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	cfg          *config.RocketPoolConfig
+	ec           rocketpool.ExecutionClient
+	rp           *rocketpool.RocketPool
+	bc           beacon.Client
+	mgr          *stateManager
+	outputFormat string
+}
+
+// The rule a proposal broke
+type penaltyReason string
+
+const (
+	penaltyReasonSmoothingPoolTheft  penaltyReason = "smoothing-pool-theft"
+	penaltyReasonLateOptOut          penaltyReason = "late-smoothing-pool-opt-out"
+	penaltyReasonIllegalFeeRecipient penaltyReason = "illegal-fee-recipient"
+)
+
+// A proposal that would incur a penalty
+type feeRecipientPenalty struct {
+	Slot                 uint64         `json:"slot"`
+	ExecutionBlock       uint64         `json:"executionBlock"`
+	ProposerIndex        uint64         `json:"proposerIndex"`
+	Minipool             common.Address `json:"minipool"`
+	Node                 common.Address `json:"node"`
+	Reason               penaltyReason  `json:"reason"`
+	ExpectedFeeRecipient common.Address `json:"expectedFeeRecipient"`
+	ActualFeeRecipient   common.Address `json:"actualFeeRecipient"`
+	OptOutTime           *time.Time     `json:"optOutTime,omitempty"`
+	SafeOptOutTime       *time.Time     `json:"safeOptOutTime,omitempty"`
+}
+
+// Machine-readable penalty check report
+type checkPenaltiesOutput struct {
+	FromSlot          uint64                `json:"fromSlot"`
+	ToSlot            uint64                `json:"toSlot"`
+	BlocksChecked     int                   `json:"blocksChecked"`
+	MinipoolProposals int                   `json:"minipoolProposals"`
+	Penalties         []feeRecipientPenalty `json:"penalties"`
+}
+
+// The result of checking a single slot
+type slotPenaltyCheck struct {
+	exists     bool
+	isMinipool bool
+	penalty    *feeRecipientPenalty
+}
+
+// Create check penalties task
+func newCheckPenalties(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*checkPenalties, error) {
+
+	if !c.IsSet("from-slot") {
+		return nil, fmt.Errorf("from-slot must be provided")
+	}
+	if c.Int("workers") < 1 {
+		return nil, fmt.Errorf("workers must be at least 1")
+	}
+
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ec, bc, rp, cfg, mgr, err := initialize(c, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing RP artifacts: %w", err)
+	}
+
+	// Return task
+	return &checkPenalties{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		cfg:          cfg,
+		ec:           ec,
+		rp:           rp,
+		bc:           bc,
+		mgr:          mgr,
+		outputFormat: outputFormat,
+	}, nil
+
+}
+
+// Check the fee recipients of the blocks in a slot range
+func (t *checkPenalties) run() error {
+
+	// Get the slot range
+	fromSlot := t.c.Uint64("from-slot")
+	toSlot := t.c.Uint64("to-slot")
+	if !t.c.IsSet("to-slot") {
+		head, exists, err := t.bc.GetBeaconBlock("finalized")
+		if err != nil {
+			return fmt.Errorf("error getting finalized beacon block: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("the finalized beacon block doesn't exist")
+		}
+		toSlot = head.Slot
+		t.log.Printlnf("to-slot not set, using the latest finalized slot (%d).", toSlot)
+	}
+	if toSlot < fromSlot {
+		return fmt.Errorf("to-slot (%d) is before from-slot (%d)", toSlot, fromSlot)
+	}
+
+	// Get the state at the end of the range to map proposers to minipools
+	t.log.Printlnf("Getting network state for slot %d...", toSlot)
+	state, err := t.mgr.GetStateForSlot(toSlot)
+	if err != nil {
+		return fmt.Errorf("error getting state for beacon slot %d: %w", toSlot, err)
+	}
+	minipoolsByIndex := map[uint64]*rpstate.NativeMinipoolDetails{}
+	for i, mpd := range state.MinipoolDetails {
+		status, exists := state.ValidatorDetails[mpd.Pubkey]
+		if exists && status.Exists {
+			minipoolsByIndex[status.Index] = &state.MinipoolDetails[i]
+		}
+	}
+
+	// Check each slot
+	t.log.Printlnf("Checking the fee recipients of slots %d to %d...", fromSlot, toSlot)
+	results := make([]slotPenaltyCheck, toSlot-fromSlot+1)
+	var wg errgroup.Group
+	wg.SetLimit(t.c.Int("workers"))
+	for slot := fromSlot; slot <= toSlot; slot++ {
+		slot := slot
+		wg.Go(func() error {
+			result, err := t.checkSlot(slot, state, minipoolsByIndex)
+			if err != nil {
+				return fmt.Errorf("error checking slot %d: %w", slot, err)
+			}
+			results[slot-fromSlot] = result
+			return nil
+		})
+	}
+	if err := wg.Wait(); err != nil {
+		return err
+	}
+
+	// Collect the penalties in slot order
+	output := checkPenaltiesOutput{
+		FromSlot:  fromSlot,
+		ToSlot:    toSlot,
+		Penalties: []feeRecipientPenalty{},
+	}
+	for _, result := range results {
+		if result.exists {
+			output.BlocksChecked++
+		}
+		if result.isMinipool {
+			output.MinipoolProposals++
+		}
+		if result.penalty != nil {
+			output.Penalties = append(output.Penalties, *result.penalty)
+		}
+	}
+
+	// Print the results
+	t.log.Printlnf("Checked %d blocks, %d of which were proposed by minipools.", output.BlocksChecked, output.MinipoolProposals)
+	if len(output.Penalties) == 0 {
+		t.log.Println("No blocks would incur a penalty.")
+	}
+	for _, penalty := range output.Penalties {
+		t.errLog.Printlnf("Slot %d (EL block %d) would incur a penalty: %s", penalty.Slot, penalty.ExecutionBlock, penalty.Reason)
+		t.errLog.Printlnf("\tMinipool: %s", penalty.Minipool.Hex())
+		t.errLog.Printlnf("\tNode: %s", penalty.Node.Hex())
+		t.errLog.Printlnf("\tExpected fee recipient: %s", penalty.ExpectedFeeRecipient.Hex())
+		t.errLog.Printlnf("\tActual fee recipient: %s", penalty.ActualFeeRecipient.Hex())
+		if penalty.OptOutTime != nil {
+			t.errLog.Printlnf("\tOpted out at %s, after the safe opt out time of %s", penalty.OptOutTime.Format(time.RFC3339), penalty.SafeOptOutTime.Format(time.RFC3339))
+		}
+	}
+
+	// Print the machine-readable report
+	if t.outputFormat == outputFormatJson {
+		return printJson(output)
+	}
+	return nil
+
+}
+
+// Check the fee recipient of the block in a slot, the same way the watchtower does
+func (t *checkPenalties) checkSlot(slot uint64, state *state.NetworkState, minipoolsByIndex map[uint64]*rpstate.NativeMinipoolDetails) (slotPenaltyCheck, error) {
+
+	result := slotPenaltyCheck{}
+	block, exists, err := t.bc.GetBeaconBlock(strconv.FormatUint(slot, 10))
+	if err != nil {
+		return result, fmt.Errorf("error getting beacon block: %w", err)
+	}
+	result.exists = exists
+
+	// Ignore missed slots, blocks from before the merge and proposers that aren't minipools
+	if !exists || !block.HasExecutionPayload {
+		return result, nil
+	}
+	mpd, exists := minipoolsByIndex[block.ProposerIndex]
+	if !exists {
+		return result, nil
+	}
+	result.isMinipool = true
+
+	// Get the node's distributor and the Smoothing Pool as of the block
+	opts := &bind.CallOpts{
+		BlockNumber: big.NewInt(0).SetUint64(block.ExecutionBlockNumber),
+	}
+	distributorAddress := state.NodeDetailsByAddress[mpd.NodeAddress].FeeDistributorAddress
+	smoothingPoolAddressPtr, err := t.rp.GetAddress("rocketSmoothingPool", opts)
+	if err != nil {
+		return result, fmt.Errorf("error getting smoothing pool address: %w", err)
+	}
+	smoothingPoolAddress := *smoothingPoolAddressPtr
+
+	// Blocks sent to the Smoothing Pool or the rETH contract are always fine
+	if smoothingPoolAddress != (common.Address{}) && block.FeeRecipient == smoothingPoolAddress {
+		return result, nil
+	}
+	if block.FeeRecipient == t.cfg.Smartnode.GetRethAddress() {
+		return result, nil
+	}
+
+	penalty := &feeRecipientPenalty{
+		Slot:               block.Slot,
+		ExecutionBlock:     block.ExecutionBlockNumber,
+		ProposerIndex:      block.ProposerIndex,
+		Minipool:           mpd.MinipoolAddress,
+		Node:               mpd.NodeAddress,
+		ActualFeeRecipient: block.FeeRecipient,
+	}
+
+	// Check for Smoothing Pool theft
+	isOptedIn, err := node.GetSmoothingPoolRegistrationState(t.rp, mpd.NodeAddress, opts)
+	if err != nil {
+		return result, fmt.Errorf("error checking if node %s was opted into the smoothing pool: %w", mpd.NodeAddress.Hex(), err)
+	}
+	if isOptedIn {
+		penalty.Reason = penaltyReasonSmoothingPoolTheft
+		penalty.ExpectedFeeRecipient = smoothingPoolAddress
+		result.penalty = penalty
+		return result, nil
+	}
+
+	// Make sure the node didn't opt out in order to steal the block
+	optOutTime, err := node.GetSmoothingPoolRegistrationChanged(t.rp, mpd.NodeAddress, opts)
+	if err != nil {
+		return result, fmt.Errorf("error checking when node %s opted out of the smoothing pool: %w", mpd.NodeAddress.Hex(), err)
+	}
+	if optOutTime != time.Unix(0, 0) {
+		// Opting out after the start of the previous epoch is cheating
+		previousEpoch := block.Slot / state.BeaconConfig.SlotsPerEpoch
+		if previousEpoch > 0 {
+			previousEpoch--
+		}
+		safeOptOutTime := getSlotTime(previousEpoch*state.BeaconConfig.SlotsPerEpoch, state.BeaconConfig)
+		if optOutTime.After(safeOptOutTime) {
+			optOutTime = optOutTime.UTC()
+			safeOptOutTime = safeOptOutTime.UTC()
+			penalty.Reason = penaltyReasonLateOptOut
+			penalty.ExpectedFeeRecipient = smoothingPoolAddress
+			penalty.OptOutTime = &optOutTime
+			penalty.SafeOptOutTime = &safeOptOutTime
+			result.penalty = penalty
+			return result, nil
+		}
+	}
+
+	// Check for distributor theft
+	if block.FeeRecipient != distributorAddress {
+		penalty.Reason = penaltyReasonIllegalFeeRecipient
+		penalty.ExpectedFeeRecipient = distributorAddress
+		result.penalty = penalty
+	}
+	return result, nil
+
+}
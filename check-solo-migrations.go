@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	rptypes "github.com/rocket-pool/rocketpool-go/types"
+	"github.com/rocket-pool/rocketpool-go/utils/eth"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	"github.com/rocket-pool/smartnode/shared/services/state"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Settings, matching the watchtower
+const (
+	soloMigrationCheckThreshold float64 = 0.85 // Fraction of PromotionStakePeriod that can go before a minipool gets scrubbed for not having changed to 0x01
+	soloMigrationBlsPrefix      byte    = 0x00
+	soloMigrationElPrefix       byte    = 0x01
+	soloMigrationBalanceBuffer  float64 = 0.001
+	soloMigrationMinBalance     uint64  = 32000000000
+)
+
+// Check solo migrations task
+type checkSoloMigrations struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	ec           rocketpool.ExecutionClient
+	mgr          *stateManager
+	outputFormat string
+}
+
+// A vacant minipool created by a solo validator migration
+type soloMigration struct {
+	Address               common.Address          `json:"address"`
+	NodeAddress           common.Address          `json:"nodeAddress"`
+	Pubkey                rptypes.ValidatorPubkey `json:"pubkey"`
+	ValidatorStatus       beacon.ValidatorState   `json:"validatorStatus,omitempty"`
+	WithdrawalCredentials string                  `json:"withdrawalCredentials,omitempty"`
+	PreMigrationBalance   uint64                  `json:"preMigrationBalance"`
+	CurrentBalance        uint64                  `json:"currentBalance"`
+	Scrub                 bool                    `json:"scrub"`
+	Reason                string                  `json:"reason"`
+}
+
+// Machine-readable solo migration check report
+type checkSoloMigrationsOutput struct {
+	ElBlock    uint64          `json:"elBlock"`
+	BeaconSlot uint64          `json:"beaconSlot"`
+	Migrations []soloMigration `json:"migrations"`
+}
+
+// Create check solo migrations task
+func newCheckSoloMigrations(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*checkSoloMigrations, error) {
+
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ec, _, _, _, mgr, err := initialize(c, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing RP artifacts: %w", err)
+	}
+
+	// Return task
+	return &checkSoloMigrations{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		ec:           ec,
+		mgr:          mgr,
+		outputFormat: outputFormat,
+	}, nil
+
+}
+
+// Check for solo migrations to scrub
+func (t *checkSoloMigrations) run() error {
+
+	state, err := getTargetState(t.c, t.ec, t.mgr, t.log)
+	if err != nil {
+		return err
+	}
+
+	output := checkSoloMigrationsOutput{
+		ElBlock:    state.ElBlockNumber,
+		BeaconSlot: state.BeaconSlotNumber,
+		Migrations: []soloMigration{},
+	}
+	if !state.IsAtlasDeployed {
+		t.log.Printlnf("Atlas isn't deployed as of EL block %d, so there are no solo migrations to check.", state.ElBlockNumber)
+	} else {
+		output.Migrations = getSoloMigrations(state)
+	}
+
+	// Print the results
+	t.log.Printlnf("Found %d vacant minipools at EL block %d, CL slot %d.", len(output.Migrations), state.ElBlockNumber, state.BeaconSlotNumber)
+	scrubCount := 0
+	for _, migration := range output.Migrations {
+		if migration.Scrub {
+			scrubCount++
+			t.errLog.Printlnf("Minipool %s (node %s) would be scrubbed: %s", migration.Address.Hex(), migration.NodeAddress.Hex(), migration.Reason)
+		} else {
+			t.log.Printlnf("Minipool %s (node %s): %s", migration.Address.Hex(), migration.NodeAddress.Hex(), migration.Reason)
+		}
+	}
+	if scrubCount == 0 {
+		t.log.Println("No vacant minipools would be scrubbed.")
+	} else {
+		t.log.Printlnf("%d vacant minipool(s) would be scrubbed.", scrubCount)
+	}
+
+	// Print the machine-readable report
+	if t.outputFormat == outputFormatJson {
+		return printJson(output)
+	}
+	return nil
+
+}
+
+// Get the vacant minipools, and whether the watchtower would scrub each one
+func getSoloMigrations(state *state.NetworkState) []soloMigration {
+
+	oneGwei := eth.GweiToWei(1)
+	scrubThreshold := time.Duration(state.NetworkDetails.PromotionScrubPeriod.Seconds()*soloMigrationCheckThreshold) * time.Second
+	blockTime := getSlotTime(state.BeaconSlotNumber, state.BeaconConfig)
+	buffer := uint64(soloMigrationBalanceBuffer * eth.WeiPerGwei)
+
+	migrations := []soloMigration{}
+	for _, mpd := range state.MinipoolDetails {
+		// Ignore minipools that aren't vacant or are already dissolved
+		if !mpd.IsVacant || mpd.Status == rptypes.Dissolved {
+			continue
+		}
+
+		migration := soloMigration{
+			Address:             mpd.MinipoolAddress,
+			NodeAddress:         mpd.NodeAddress,
+			Pubkey:              mpd.Pubkey,
+			PreMigrationBalance: big.NewInt(0).Div(mpd.PreMigrationBalance, oneGwei).Uint64(),
+		}
+		scrub := func(reason string) {
+			migration.Scrub = true
+			migration.Reason = reason
+			migrations = append(migrations, migration)
+		}
+
+		// The validator has to be active on Beacon
+		validator := state.ValidatorDetails[mpd.Pubkey]
+		if !validator.Exists {
+			scrub("validator doesn't exist on Beacon yet, but is required to be active_ongoing for migration")
+			continue
+		}
+		migration.ValidatorStatus = validator.Status
+		migration.WithdrawalCredentials = validator.WithdrawalCredentials.Hex()
+		if validator.Status != beacon.ValidatorState_ActiveOngoing {
+			scrub(fmt.Sprintf("validator is %s, but is required to be active_ongoing for migration", validator.Status))
+			continue
+		}
+
+		// Add the minipool balance to the Beacon balance in case it already got skimmed
+		migration.CurrentBalance = validator.Balance + big.NewInt(0).Div(mpd.Balance, oneGwei).Uint64()
+
+		// Check the withdrawal credentials
+		withdrawalCreds := validator.WithdrawalCredentials
+		switch withdrawalCreds[0] {
+		case soloMigrationBlsPrefix:
+			creationTime := time.Unix(mpd.StatusTime.Int64(), 0)
+			remainingTime := creationTime.Add(scrubThreshold).Sub(blockTime)
+			if remainingTime < 0 {
+				scrub(fmt.Sprintf("withdrawal credentials weren't changed to the minipool in time (created %s, scrubbed after %s)", creationTime.UTC().Format(time.RFC3339), scrubThreshold))
+			} else {
+				migration.Reason = fmt.Sprintf("waiting for the withdrawal credentials to change to the minipool (%s remaining)", remainingTime)
+				migrations = append(migrations, migration)
+			}
+			continue
+		case soloMigrationElPrefix:
+			if withdrawalCreds != mpd.WithdrawalCredentials {
+				scrub(fmt.Sprintf("withdrawal credentials don't match (expected %s, actual %s)", mpd.WithdrawalCredentials.Hex(), withdrawalCreds.Hex()))
+				continue
+			}
+		default:
+			scrub(fmt.Sprintf("unexpected prefix in withdrawal credentials: %s", withdrawalCreds.Hex()))
+			continue
+		}
+
+		// Check the balance
+		if migration.CurrentBalance < soloMigrationMinBalance {
+			scrub(fmt.Sprintf("current balance of %d gwei is lower than the threshold of %d gwei", migration.CurrentBalance, soloMigrationMinBalance))
+			continue
+		}
+		if migration.CurrentBalance < (migration.PreMigrationBalance - buffer) {
+			scrub(fmt.Sprintf("current balance of %d gwei is lower than the pre-migration balance of %d gwei, minus the buffer of %d gwei", migration.CurrentBalance, migration.PreMigrationBalance, buffer))
+			continue
+		}
+
+		migration.Reason = "withdrawal credentials and balance are valid, ready to promote"
+		migrations = append(migrations, migration)
+	}
+
+	return migrations
+
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// The prefix of the environment variables that set options
+const configEnvPrefix string = "ODAOTOOL"
+
+// The key of the config sources in the app's metadata
+const configMetadataKey string = "config"
+
+// Where an option's value came from
+const (
+	configSourceFlag    string = "flag"
+	configSourceEnv     string = "env"
+	configSourceFile    string = "file"
+	configSourceDefault string = "default"
+)
+
+// The environment variables and config file that options can be set by, besides the command line.
+// Options given on the command line take precedence over environment variables, which take precedence over the config
+// file. In the config file, global options are top-level keys, and the options of a command are in a section named
+// after it (with the options of its subcommands in nested sections).
+type configSources struct {
+	path     string
+	settings map[string]any
+
+	// Where each option of the commands that ran got its value from, keyed by its command path and name
+	// (e.g. "cache.prune.max-age")
+	sources map[string]string
+}
+
+// Let every option be set by an environment variable or the config file
+func initConfigSources(app *cli.App) {
+
+	sources := &configSources{
+		sources: map[string]string{},
+	}
+	if app.Metadata == nil {
+		app.Metadata = map[string]any{}
+	}
+	app.Metadata[configMetadataKey] = sources
+
+	app.Before = chainBefore(app.Before, func(c *cli.Context) error {
+		err := sources.load(c, app.Flags, app.Commands)
+		if err != nil {
+			return err
+		}
+		return sources.apply(c, nil, app.Flags)
+	})
+	setCommandConfigSources(sources, app.Commands, nil)
+
+}
+
+// Apply the config sources to each command and its subcommands when they run
+func setCommandConfigSources(sources *configSources, commands []*cli.Command, parentPath []string) {
+	for _, command := range commands {
+		command := command
+		path := append(append([]string{}, parentPath...), command.Name)
+		command.Before = chainBefore(command.Before, func(c *cli.Context) error {
+			return sources.apply(c, path, command.Flags)
+		})
+		setCommandConfigSources(sources, command.Subcommands, path)
+	}
+}
+
+// Get the config sources from the app's metadata
+func getConfigSources(c *cli.Context) *configSources {
+	sources, _ := c.App.Metadata[configMetadataKey].(*configSources)
+	return sources
+}
+
+// Get the environment variable that sets an option, e.g. ODAOTOOL_EC_ENDPOINT or ODAOTOOL_CACHE_PRUNE_MAX_AGE
+func getEnvVarName(commandPath []string, flagName string) string {
+	parts := append(append([]string{configEnvPrefix}, commandPath...), flagName)
+	return strings.ToUpper(strings.ReplaceAll(strings.Join(parts, "_"), "-", "_"))
+}
+
+// Get the key of an option, e.g. "cache.prune.max-age"
+func getConfigKey(commandPath []string, flagName string) string {
+	return strings.Join(append(append([]string{}, commandPath...), flagName), ".")
+}
+
+// Run a Before function after an existing one, if there is one
+func chainBefore(existing cli.BeforeFunc, next cli.BeforeFunc) cli.BeforeFunc {
+	if existing == nil {
+		return next
+	}
+	return func(c *cli.Context) error {
+		err := existing(c)
+		if err != nil {
+			return err
+		}
+		return next(c)
+	}
+}
+
+// Load and validate the config file, if one was provided
+func (s *configSources) load(c *cli.Context, flags []cli.Flag, commands []*cli.Command) error {
+
+	// The config file itself can only be set on the command line or by its environment variable
+	path := c.String("config")
+	if !c.IsSet("config") {
+		path = os.Getenv(getEnvVarName(nil, "config"))
+	}
+	if path == "" {
+		return nil
+	}
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	settings := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(bytes, &settings)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(bytes, &settings)
+	default:
+		return fmt.Errorf("config file %s must be YAML (.yaml or .yml) or TOML (.toml)", path)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	err = validateConfigSection(settings, nil, flags, commands)
+	if err != nil {
+		return fmt.Errorf("error in config file %s: %w", path, err)
+	}
+	s.path = path
+	s.settings = settings
+	return nil
+
+}
+
+// Check that every setting in a section of the config file is an option or a command section
+func validateConfigSection(section map[string]any, commandPath []string, flags []cli.Flag, commands []*cli.Command) error {
+
+	for key, value := range section {
+		subsection, isSection := value.(map[string]any)
+		if findFlag(flags, key) != nil {
+			if key == "config" && commandPath == nil {
+				return fmt.Errorf("config can't be set from the config file")
+			}
+			if isSection {
+				return fmt.Errorf("%s must be a value, not a section", getConfigKey(commandPath, key))
+			}
+			continue
+		}
+		command := findCommand(commands, key)
+		if command == nil {
+			return fmt.Errorf("unknown setting %s", getConfigKey(commandPath, key))
+		}
+		if !isSection {
+			return fmt.Errorf("%s must be a section of options for the %s command", getConfigKey(commandPath, key), key)
+		}
+		path := append(append([]string{}, commandPath...), key)
+		err := validateConfigSection(subsection, path, command.Flags, command.Subcommands)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+
+}
+
+// Get the value of an option from its environment variable or the config file, along with which one it came from
+func (s *configSources) lookup(commandPath []string, flagName string) (any, string, bool) {
+
+	value, exists := os.LookupEnv(getEnvVarName(commandPath, flagName))
+	if exists {
+		return value, configSourceEnv, true
+	}
+
+	section := s.settings
+	for _, name := range commandPath {
+		section, _ = section[name].(map[string]any)
+	}
+	setting, exists := section[flagName]
+	if exists {
+		return setting, configSourceFile, true
+	}
+	return nil, "", false
+
+}
+
+// Set the options of a command that weren't given on the command line from their environment variables or the config
+// file
+func (s *configSources) apply(c *cli.Context, commandPath []string, flags []cli.Flag) error {
+
+	for _, flag := range flags {
+		if !isConfigurable(flag) {
+			continue
+		}
+		name := flag.Names()[0]
+		key := getConfigKey(commandPath, name)
+		if c.IsSet(name) {
+			s.sources[key] = configSourceFlag
+			continue
+		}
+		value, source, exists := s.lookup(commandPath, name)
+		if !exists {
+			continue
+		}
+
+		// Lists from the config file set each of their elements
+		values, isList := value.([]any)
+		if !isList {
+			values = []any{value}
+		}
+		for _, element := range values {
+			err := c.Set(name, formatConfigValue(element))
+			if err != nil {
+				if source == configSourceEnv {
+					return fmt.Errorf("invalid value for %s in %s: %w", name, getEnvVarName(commandPath, name), err)
+				}
+				return fmt.Errorf("invalid value for %s in config file %s: %w", key, s.path, err)
+			}
+		}
+		s.sources[key] = source
+	}
+	return nil
+
+}
+
+// Format a setting from the config file the way it would be given on the command line
+func formatConfigValue(value any) string {
+	switch value := value.(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// Check if a flag is an option, rather than one of the built-in help and version flags
+func isConfigurable(flag cli.Flag) bool {
+	return flag != cli.HelpFlag && flag != cli.VersionFlag
+}
+
+// Find a configurable flag by its name
+func findFlag(flags []cli.Flag, name string) cli.Flag {
+	for _, flag := range flags {
+		if isConfigurable(flag) && flag.Names()[0] == name {
+			return flag
+		}
+	}
+	return nil
+}
+
+// Find a command by its name
+func findCommand(commands []*cli.Command, name string) *cli.Command {
+	for _, command := range commands {
+		if command.Name == name {
+			return command
+		}
+	}
+	return nil
+}
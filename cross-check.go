@@ -0,0 +1,487 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/rocket-pool/rocketpool-go/network"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	cfgtypes "github.com/rocket-pool/smartnode/shared/types/config"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Matches an endpoint given as name=url; URLs themselves never match, since a scheme contains a colon
+var namedEndpointPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+)=(.+)$`)
+
+// Cross-check task
+type crossCheck struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	pairs        []*crossCheckPair
+	top          int
+	outputFormat string
+}
+
+// An EC and BN to simulate the duties with
+type crossCheckPair struct {
+	name     string
+	ecUrl    string
+	bnUrl    string
+	rp       *rocketpool.RocketPool
+	bc       beacon.Client
+	price    *submitRplPrice
+	balances *submitNetworkBalances
+}
+
+// The values each pair got for one result, and whether they're all the same
+type crossCheckValue struct {
+	Name   string            `json:"name"`
+	Values map[string]string `json:"values"`
+	Agree  bool              `json:"agree"`
+}
+
+// The comparison of one duty across the pairs
+type crossCheckDuty struct {
+	Block  uint64            `json:"block"`
+	Values []crossCheckValue `json:"values"`
+	Errors map[string]string `json:"errors"`
+	Agree  bool              `json:"agree"`
+}
+
+// The comparison of the network balances, including the minipools whose balances differ from the first pair's
+type crossCheckBalances struct {
+	crossCheckDuty
+	MinipoolDifferences map[string][]minipoolDelta `json:"minipoolDifferences"`
+}
+
+// Machine-readable cross-check report
+type crossCheckOutput struct {
+	Network         string              `json:"network"`
+	TargetBlock     uint64              `json:"targetBlock"`
+	Pairs           []string            `json:"pairs"`
+	RplPrice        *crossCheckDuty     `json:"rplPrice"`
+	NetworkBalances *crossCheckBalances `json:"networkBalances"`
+	Agree           bool                `json:"agree"`
+}
+
+// Create cross-check task
+func newCrossCheck(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*crossCheck, error) {
+
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+	if c.Int("top") < 0 {
+		return nil, fmt.Errorf("top must be non-negative")
+	}
+	if c.IsSet("record") || c.IsSet("replay") {
+		return nil, fmt.Errorf("record and replay can't be used with cross-check, since they don't separate the traffic of each pair")
+	}
+	if c.IsSet("cache-dir") {
+		logger.Println("Ignoring cache-dir, since each pair has to load the network state from its own clients.")
+	}
+
+	endpoints, err := parseEndpointPairs(c.StringSlice("ec-endpoint"), c.StringSlice("bn-endpoint"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Connect to each pair, making sure they're all on the same network
+	pairs := []*crossCheckPair{}
+	var referenceNetwork cfgtypes.Network
+	var referenceChainID uint
+	for _, pair := range endpoints {
+		logger.Printlnf("Connecting to pair %s...", pair.name)
+		ec, bc, rp, cfg, mgr, err := initializeForEndpoints(c, logger, pair.ecUrl, pair.bnUrl, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing pair %s: %w", pair.name, err)
+		}
+		mgr.cache = nil
+		if len(pairs) == 0 {
+			referenceNetwork = mgr.Network
+			referenceChainID = mgr.ChainID
+		} else if mgr.Network != referenceNetwork || mgr.ChainID != referenceChainID {
+			return nil, fmt.Errorf("pair %s is on %s (chain ID %d), but pair %s is on %s (chain ID %d)", pair.name, mgr.Network, mgr.ChainID, pairs[0].name, referenceNetwork, referenceChainID)
+		}
+
+		pair.rp = rp
+		pair.bc = bc
+		pair.price, pair.balances = newDutyTasks(c, logger, errorLogger, ec, bc, rp, cfg, mgr)
+		pairs = append(pairs, pair)
+	}
+
+	// Return task
+	return &crossCheck{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		pairs:        pairs,
+		top:          c.Int("top"),
+		outputFormat: outputFormat,
+	}, nil
+
+}
+
+// Parse the EC and BN endpoints into pairs. Endpoints can be given as name=url and are paired by name, or as plain URLs
+// that are paired in order. A single unnamed BN or EC is shared by every pair.
+func parseEndpointPairs(ecEndpoints []string, bnEndpoints []string) ([]*crossCheckPair, error) {
+
+	ecNames, ecUrls, err := parseNamedEndpoints("ec-endpoint", ecEndpoints)
+	if err != nil {
+		return nil, err
+	}
+	bnNames, bnUrls, err := parseNamedEndpoints("bn-endpoint", bnEndpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := []*crossCheckPair{}
+	switch {
+	case len(bnEndpoints) == 1 && !namedEndpointPattern.MatchString(bnEndpoints[0]):
+		for i, name := range ecNames {
+			pairs = append(pairs, &crossCheckPair{name: name, ecUrl: ecUrls[i], bnUrl: bnUrls[0]})
+		}
+	case len(ecEndpoints) == 1 && !namedEndpointPattern.MatchString(ecEndpoints[0]):
+		for i, name := range bnNames {
+			pairs = append(pairs, &crossCheckPair{name: name, ecUrl: ecUrls[0], bnUrl: bnUrls[i]})
+		}
+	default:
+		bnUrlsByName := map[string]string{}
+		for i, name := range bnNames {
+			bnUrlsByName[name] = bnUrls[i]
+		}
+		for i, name := range ecNames {
+			bnUrl, exists := bnUrlsByName[name]
+			if !exists {
+				return nil, fmt.Errorf("EC %s doesn't have a BN with the same name", name)
+			}
+			delete(bnUrlsByName, name)
+			pairs = append(pairs, &crossCheckPair{name: name, ecUrl: ecUrls[i], bnUrl: bnUrl})
+		}
+		for name := range bnUrlsByName {
+			return nil, fmt.Errorf("BN %s doesn't have an EC with the same name", name)
+		}
+	}
+
+	if len(pairs) < 2 {
+		return nil, fmt.Errorf("at least two EC/BN pairs must be provided with ec-endpoint and bn-endpoint")
+	}
+	return pairs, nil
+
+}
+
+// Split endpoints given as name=url or plain URLs into names and URLs, naming the plain ones by their position
+func parseNamedEndpoints(flagName string, endpoints []string) ([]string, []string, error) {
+	names := []string{}
+	urls := []string{}
+	seen := map[string]bool{}
+	for i, endpoint := range endpoints {
+		name := fmt.Sprint(i + 1)
+		url := endpoint
+		match := namedEndpointPattern.FindStringSubmatch(endpoint)
+		if match != nil {
+			name = match[1]
+			url = match[2]
+		}
+		if seen[name] {
+			return nil, nil, fmt.Errorf("%s %s was given more than once", flagName, name)
+		}
+		seen[name] = true
+		names = append(names, name)
+		urls = append(urls, url)
+	}
+	return names, urls, nil
+}
+
+// Simulate the price and balance duties with every pair at the same target block, and compare the results
+func (t *crossCheck) run() error {
+
+	reference := t.pairs[0]
+	targetBlock, err := t.getTargetBlock()
+	if err != nil {
+		return err
+	}
+	opts := &bind.CallOpts{
+		BlockNumber: big.NewInt(0).SetUint64(targetBlock),
+	}
+
+	// Use the first pair to resolve the duty blocks, so every pair simulates the same ones
+	reportablePricesBlock, err := network.GetLatestReportablePricesBlock(reference.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting latest reportable prices block from pair %s: %w", reference.name, err)
+	}
+	reportableBalancesBlock, err := network.GetLatestReportableBalancesBlock(reference.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting latest reportable balances block from pair %s: %w", reference.name, err)
+	}
+	pricesBlock := getDutyBlock(t.c, t.log, targetBlock, reportablePricesBlock.Uint64())
+	balancesBlock := getDutyBlock(t.c, t.log, targetBlock, reportableBalancesBlock.Uint64())
+
+	output := crossCheckOutput{
+		Network:     string(reference.price.mgr.Network),
+		TargetBlock: targetBlock,
+		Pairs:       []string{},
+	}
+	for _, pair := range t.pairs {
+		output.Pairs = append(output.Pairs, pair.name)
+	}
+	t.log.Printlnf("Getting the RPL price for block %d from each pair...", pricesBlock)
+	output.RplPrice = t.checkRplPrice(pricesBlock)
+	t.log.Printlnf("Calculating network balances for block %d with each pair...", balancesBlock)
+	output.NetworkBalances = t.checkNetworkBalances(balancesBlock)
+	output.Agree = output.RplPrice.Agree && output.NetworkBalances.Agree
+
+	// Print the report
+	if t.outputFormat == outputFormatJson {
+		err = printJson(output)
+		if err != nil {
+			return err
+		}
+	} else {
+		t.printDuty("RPL price", output.Pairs, output.RplPrice)
+		t.printDuty("Network balances", output.Pairs, &output.NetworkBalances.crossCheckDuty)
+		t.printMinipoolDifferences(output.Pairs, output.NetworkBalances.MinipoolDifferences)
+	}
+
+	if !output.Agree {
+		differences := []string{}
+		for _, duty := range []*crossCheckDuty{output.RplPrice, &output.NetworkBalances.crossCheckDuty} {
+			for _, value := range duty.Values {
+				if !value.Agree {
+					differences = append(differences, value.Name)
+				}
+			}
+			if len(duty.Errors) > 0 {
+				differences = append(differences, fmt.Sprintf("errors at block %d", duty.Block))
+			}
+		}
+		return fmt.Errorf("the pairs disagree at target block %d: %s", targetBlock, strings.Join(differences, ", "))
+	}
+	t.log.Printlnf("All %d pairs agree at target block %d.", len(t.pairs), targetBlock)
+	return nil
+
+}
+
+// Get the target block, defaulting to the lowest finalized block of the pairs so every pair has it
+func (t *crossCheck) getTargetBlock() (uint64, error) {
+
+	if t.c.IsSet("target-block") {
+		return t.c.Uint64("target-block"), nil
+	}
+
+	var targetBlock uint64
+	for i, pair := range t.pairs {
+		finalized, exists, err := pair.bc.GetBeaconBlock("finalized")
+		if err != nil {
+			return 0, fmt.Errorf("error getting finalized beacon block from pair %s: %w", pair.name, err)
+		}
+		if !exists || !finalized.HasExecutionPayload {
+			return 0, fmt.Errorf("finalized beacon block %d of pair %s doesn't have an execution payload", finalized.Slot, pair.name)
+		}
+		if i == 0 || finalized.ExecutionBlockNumber < targetBlock {
+			targetBlock = finalized.ExecutionBlockNumber
+		}
+	}
+	t.log.Printlnf("Target block not set, using the lowest finalized block of the pairs (%d).", targetBlock)
+	return targetBlock, nil
+
+}
+
+// Compare the TWAP pool observations and resulting RPL price of every pair
+func (t *crossCheck) checkRplPrice(blockNumber uint64) *crossCheckDuty {
+
+	responses := make([]poolObserveResponse, len(t.pairs))
+	errs := make([]error, len(t.pairs))
+	var wg sync.WaitGroup
+	for i, pair := range t.pairs {
+		wg.Add(1)
+		go func(i int, pair *crossCheckPair) {
+			defer wg.Done()
+			responses[i], _, errs[i] = pair.price.getRplTwapObservation(blockNumber)
+		}(i, pair)
+	}
+	wg.Wait()
+
+	labels := []string{
+		fmt.Sprintf("Tick cumulative %ds ago", twapNumberOfSeconds),
+		"Tick cumulative at block",
+	}
+	duty := t.newDuty(blockNumber, errs)
+	for index, label := range labels {
+		duty.addValue(label, t.pairs, errs, func(i int) string {
+			if index >= len(responses[i].TickCumulatives) {
+				return ""
+			}
+			return responses[i].TickCumulatives[index].String()
+		})
+	}
+	duty.addValue("RPL price", t.pairs, errs, func(i int) string {
+		if len(responses[i].TickCumulatives) != len(labels) {
+			return ""
+		}
+		return getRplPriceFromObservation(responses[i], twapNumberOfSeconds).String()
+	})
+	return duty
+
+}
+
+// Compare the network balances of every pair
+func (t *crossCheck) checkNetworkBalances(blockNumber uint64) *crossCheckBalances {
+
+	// Each state load holds the entire network state in memory, so the pairs are done one at a time
+	balances := make([]networkBalances, len(t.pairs))
+	slots := make([]uint64, len(t.pairs))
+	errs := make([]error, len(t.pairs))
+	for i, pair := range t.pairs {
+		t.log.Printlnf("Calculating network balances with pair %s...", pair.name)
+		balances[i], slots[i], errs[i] = pair.balances.getNetworkBalancesForBlock(blockNumber)
+	}
+
+	duty := &crossCheckBalances{
+		crossCheckDuty:      *t.newDuty(blockNumber, errs),
+		MinipoolDifferences: map[string][]minipoolDelta{},
+	}
+	components := []struct {
+		name  string
+		value func(b *networkBalances) *big.Int
+	}{
+		{"Deposit pool", func(b *networkBalances) *big.Int { return b.DepositPool }},
+		{"Minipools", func(b *networkBalances) *big.Int { return b.MinipoolsTotal }},
+		{"Minipools staking", func(b *networkBalances) *big.Int { return b.MinipoolsStaking }},
+		{"Fee distributor share", func(b *networkBalances) *big.Int { return b.DistributorShareTotal }},
+		{"Smoothing pool share", func(b *networkBalances) *big.Int { return b.SmoothingPoolShare }},
+		{"rETH contract", func(b *networkBalances) *big.Int { return b.RETHContract }},
+		{"Node credit", func(b *networkBalances) *big.Int { return b.NodeCreditBalance }},
+		{"Total ETH", func(b *networkBalances) *big.Int { return b.getTotalEth() }},
+		{"rETH supply", func(b *networkBalances) *big.Int { return b.RETHSupply }},
+	}
+	duty.addValue("Beacon slot", t.pairs, errs, func(i int) string {
+		return fmt.Sprint(slots[i])
+	})
+	for _, component := range components {
+		component := component
+		duty.addValue(component.name, t.pairs, errs, func(i int) string {
+			return component.value(&balances[i]).String()
+		})
+	}
+
+	// Find the minipools behind any difference from the first pair
+	for i, pair := range t.pairs[1:] {
+		if errs[0] != nil || errs[i+1] != nil {
+			continue
+		}
+		deltas := getMinipoolDeltas(balances[0].Minipools, balances[i+1].Minipools)
+		if len(deltas) == 0 {
+			continue
+		}
+		if len(deltas) > t.top {
+			deltas = deltas[:t.top]
+		}
+		duty.MinipoolDifferences[pair.name] = deltas
+	}
+	return duty
+
+}
+
+// Create the comparison of a duty, recording the pairs that failed to simulate it
+func (t *crossCheck) newDuty(blockNumber uint64, errs []error) *crossCheckDuty {
+	duty := &crossCheckDuty{
+		Block:  blockNumber,
+		Values: []crossCheckValue{},
+		Errors: map[string]string{},
+		Agree:  true,
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.errLog.Printlnf("Pair %s failed at block %d: %s", t.pairs[i].name, blockNumber, err.Error())
+			duty.Errors[t.pairs[i].name] = err.Error()
+			duty.Agree = false
+		}
+	}
+	return duty
+}
+
+// Add a value to a duty's comparison, skipping the pairs that failed
+func (d *crossCheckDuty) addValue(name string, pairs []*crossCheckPair, errs []error, getValue func(i int) string) {
+	value := crossCheckValue{
+		Name:   name,
+		Values: map[string]string{},
+		Agree:  true,
+	}
+	var first string
+	for i, pair := range pairs {
+		if errs[i] != nil {
+			continue
+		}
+		pairValue := getValue(i)
+		value.Values[pair.name] = pairValue
+		if len(value.Values) == 1 {
+			first = pairValue
+		} else if pairValue != first {
+			value.Agree = false
+		}
+	}
+	if !value.Agree {
+		d.Agree = false
+	}
+	d.Values = append(d.Values, value)
+}
+
+// Print a duty's comparison as a table, marking the values that differ
+func (t *crossCheck) printDuty(title string, pairs []string, duty *crossCheckDuty) {
+
+	fmt.Printf("\n%s at block %d:\n\n", title, duty.Block)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "Value\t%s\t\t\n", strings.Join(pairs, "\t"))
+	for _, value := range duty.Values {
+		row := []string{}
+		for _, pair := range pairs {
+			pairValue, exists := value.Values[pair]
+			if !exists {
+				pairValue = "error"
+			}
+			row = append(row, pairValue)
+		}
+		status := ""
+		if !value.Agree {
+			status = "DIFFERS"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", value.Name, strings.Join(row, "\t"), status)
+	}
+	w.Flush()
+
+	for _, pair := range pairs {
+		err, exists := duty.Errors[pair]
+		if exists {
+			fmt.Printf("Pair %s failed: %s\n", pair, err)
+		}
+	}
+
+}
+
+// Print the minipools whose user balances differ from the first pair's
+func (t *crossCheck) printMinipoolDifferences(pairs []string, differences map[string][]minipoolDelta) {
+	for _, pair := range pairs[1:] {
+		deltas, exists := differences[pair]
+		if !exists {
+			continue
+		}
+		fmt.Printf("\nMinipools whose user balance differs between pairs %s and %s (top %d by absolute difference):\n\n", pairs[0], pair, len(deltas))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(w, "Minipool\tBranch (%s)\tBranch (%s)\t%s (wei)\t%s (wei)\tDifference (wei)\t\n", pairs[0], pair, pairs[0], pair)
+		for _, mp := range deltas {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", mp.Address.Hex(), mp.FromBranch, mp.ToBranch, mp.From, mp.To, mp.Delta)
+		}
+		w.Flush()
+	}
+}
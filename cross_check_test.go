@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEndpointPairs(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		ecs      []string
+		bns      []string
+		expected []crossCheckPair
+		err      string
+	}{
+		{
+			name: "named",
+			ecs:  []string{"geth=http://a:8545", "nethermind=http://b:8545"},
+			bns:  []string{"nethermind=http://b:5052", "geth=http://a:5052"},
+			expected: []crossCheckPair{
+				{name: "geth", ecUrl: "http://a:8545", bnUrl: "http://a:5052"},
+				{name: "nethermind", ecUrl: "http://b:8545", bnUrl: "http://b:5052"},
+			},
+		},
+		{
+			name: "positional",
+			ecs:  []string{"http://a:8545", "http://b:8545"},
+			bns:  []string{"http://a:5052", "http://b:5052"},
+			expected: []crossCheckPair{
+				{name: "1", ecUrl: "http://a:8545", bnUrl: "http://a:5052"},
+				{name: "2", ecUrl: "http://b:8545", bnUrl: "http://b:5052"},
+			},
+		},
+		{
+			name: "shared BN",
+			ecs:  []string{"geth=http://a:8545", "besu=http://b:8545"},
+			bns:  []string{"http://shared:5052"},
+			expected: []crossCheckPair{
+				{name: "geth", ecUrl: "http://a:8545", bnUrl: "http://shared:5052"},
+				{name: "besu", ecUrl: "http://b:8545", bnUrl: "http://shared:5052"},
+			},
+		},
+		{
+			name: "shared EC",
+			ecs:  []string{"http://shared:8545"},
+			bns:  []string{"lighthouse=http://a:5052", "teku=http://b:5052"},
+			expected: []crossCheckPair{
+				{name: "lighthouse", ecUrl: "http://shared:8545", bnUrl: "http://a:5052"},
+				{name: "teku", ecUrl: "http://shared:8545", bnUrl: "http://b:5052"},
+			},
+		},
+		{
+			name: "EC without a BN",
+			ecs:  []string{"geth=http://a:8545", "besu=http://b:8545"},
+			bns:  []string{"geth=http://a:5052", "teku=http://b:5052"},
+			err:  "EC besu doesn't have a BN",
+		},
+		{
+			name: "BN without an EC",
+			ecs:  []string{"geth=http://a:8545", "besu=http://b:8545"},
+			bns:  []string{"geth=http://a:5052", "besu=http://b:5052", "teku=http://c:5052"},
+			err:  "BN teku doesn't have an EC",
+		},
+		{
+			name: "duplicate EC",
+			ecs:  []string{"geth=http://a:8545", "geth=http://b:8545"},
+			bns:  []string{"geth=http://a:5052", "besu=http://b:5052"},
+			err:  "ec-endpoint geth was given more than once",
+		},
+		{
+			name: "duplicate BN",
+			ecs:  []string{"geth=http://a:8545", "besu=http://b:8545"},
+			bns:  []string{"besu=http://a:5052", "besu=http://b:5052"},
+			err:  "bn-endpoint besu was given more than once",
+		},
+		{
+			name: "one pair",
+			ecs:  []string{"geth=http://a:8545"},
+			bns:  []string{"geth=http://a:5052"},
+			err:  "at least two EC/BN pairs",
+		},
+		{
+			name: "no pairs",
+			err:  "at least two EC/BN pairs",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pairs, err := parseEndpointPairs(test.ecs, test.bns)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error parsing endpoints: %s", err)
+			}
+			parsed := []crossCheckPair{}
+			for _, pair := range pairs {
+				parsed = append(parsed, crossCheckPair{name: pair.name, ecUrl: pair.ecUrl, bnUrl: pair.bnUrl})
+			}
+			if !reflect.DeepEqual(parsed, test.expected) {
+				t.Errorf("expected pairs %+v, got %+v", test.expected, parsed)
+			}
+		})
+	}
+
+}
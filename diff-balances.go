@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Diff network balances task
+type diffBalances struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	balances     *submitNetworkBalances
+	outputFormat string
+}
+
+// The change in one component of the total ETH balance
+type componentDelta struct {
+	Name         string `json:"name"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Contribution string `json:"contribution"`
+}
+
+// The change in a single minipool's user balance
+type minipoolDelta struct {
+	Address    common.Address `json:"address"`
+	FromBranch string         `json:"fromBranch"`
+	ToBranch   string         `json:"toBranch"`
+	From       string         `json:"from"`
+	To         string         `json:"to"`
+	Delta      string         `json:"delta"`
+	delta      *big.Int
+}
+
+// Machine-readable network balance diff
+type diffBalancesOutput struct {
+	FromBlock         uint64           `json:"fromBlock"`
+	ToBlock           uint64           `json:"toBlock"`
+	FromTotalEth      string           `json:"fromTotalEth"`
+	ToTotalEth        string           `json:"toTotalEth"`
+	TotalEthDelta     string           `json:"totalEthDelta"`
+	FromRETHSupply    string           `json:"fromRethSupply"`
+	ToRETHSupply      string           `json:"toRethSupply"`
+	FromRETHRatio     float64          `json:"fromRethRatio"`
+	ToRETHRatio       float64          `json:"toRethRatio"`
+	Components        []componentDelta `json:"components"`
+	MinipoolsChanged  int              `json:"minipoolsChanged"`
+	TopMinipoolDeltas []minipoolDelta  `json:"topMinipoolDeltas"`
+}
+
+// Create diff network balances task
+func newDiffBalances(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*diffBalances, error) {
+
+	if !c.IsSet("from") || !c.IsSet("to") {
+		return nil, fmt.Errorf("both from and to must be provided")
+	}
+	if c.Int("top") < 0 {
+		return nil, fmt.Errorf("top must be non-negative")
+	}
+
+	balances, err := newSubmitNetworkBalances(c, logger, errorLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return task
+	return &diffBalances{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		balances:     balances,
+		outputFormat: balances.outputFormat,
+	}, nil
+
+}
+
+// Explain the change in network balances between two blocks
+func (t *diffBalances) run() error {
+
+	fromBlock := t.c.Uint64("from")
+	toBlock := t.c.Uint64("to")
+
+	// Get the balances at both blocks
+	var wg errgroup.Group
+	var from networkBalances
+	var to networkBalances
+	wg.Go(func() error {
+		var err error
+		t.log.Printlnf("Calculating network balances for block %d...", fromBlock)
+		from, _, err = t.balances.getNetworkBalancesForBlock(fromBlock)
+		return err
+	})
+	wg.Go(func() error {
+		var err error
+		t.log.Printlnf("Calculating network balances for block %d...", toBlock)
+		to, _, err = t.balances.getNetworkBalancesForBlock(toBlock)
+		return err
+	})
+	if err := wg.Wait(); err != nil {
+		return err
+	}
+
+	// Attribute the change in total ETH to each component
+	nodeCreditDelta := big.NewInt(0).Sub(to.NodeCreditBalance, from.NodeCreditBalance)
+	components := []componentDelta{
+		getComponentDelta("Deposit pool", from.DepositPool, to.DepositPool),
+		getComponentDelta("Minipools", from.MinipoolsTotal, to.MinipoolsTotal),
+		getComponentDelta("Fee distributor share", from.DistributorShareTotal, to.DistributorShareTotal),
+		getComponentDelta("Smoothing pool share", from.SmoothingPoolShare, to.SmoothingPoolShare),
+		getComponentDelta("rETH contract", from.RETHContract, to.RETHContract),
+		{
+			Name:         "Node credit (subtracted)",
+			From:         from.NodeCreditBalance.String(),
+			To:           to.NodeCreditBalance.String(),
+			Contribution: nodeCreditDelta.Neg(nodeCreditDelta).String(),
+		},
+	}
+
+	// Find the minipools that contributed the most
+	minipoolDeltas := getMinipoolDeltas(from.Minipools, to.Minipools)
+	changed := len(minipoolDeltas)
+	top := t.c.Int("top")
+	if len(minipoolDeltas) > top {
+		minipoolDeltas = minipoolDeltas[:top]
+	}
+
+	fromTotal := from.getTotalEth()
+	toTotal := to.getTotalEth()
+	totalDelta := big.NewInt(0).Sub(toTotal, fromTotal)
+	fromRatio := from.getRETHRatio()
+	toRatio := to.getRETHRatio()
+
+	// Print the machine-readable report
+	if t.outputFormat == outputFormatJson {
+		return printJson(diffBalancesOutput{
+			FromBlock:         from.Block,
+			ToBlock:           to.Block,
+			FromTotalEth:      fromTotal.String(),
+			ToTotalEth:        toTotal.String(),
+			TotalEthDelta:     totalDelta.String(),
+			FromRETHSupply:    from.RETHSupply.String(),
+			ToRETHSupply:      to.RETHSupply.String(),
+			FromRETHRatio:     fromRatio,
+			ToRETHRatio:       toRatio,
+			Components:        components,
+			MinipoolsChanged:  changed,
+			TopMinipoolDeltas: minipoolDeltas,
+		})
+	}
+
+	// Print the tables
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "Component\tBlock %d (wei)\tBlock %d (wei)\tContribution (wei)\t\n", from.Block, to.Block)
+	for _, component := range components {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", component.Name, component.From, component.To, component.Contribution)
+	}
+	fmt.Fprintf(w, "Total ETH\t%s\t%s\t%s\t\n", fromTotal, toTotal, totalDelta)
+	fmt.Fprintf(w, "rETH supply\t%s\t%s\t\t\n", from.RETHSupply, to.RETHSupply)
+	fmt.Fprintf(w, "rETH ratio\t%.6f\t%.6f\t%.6f\t\n", fromRatio, toRatio, toRatio-fromRatio)
+	w.Flush()
+
+	fmt.Printf("\n%d minipools changed their user balance; the top %d by absolute change:\n\n", changed, len(minipoolDeltas))
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "Minipool\tBranch at %d\tBranch at %d\tBlock %d (wei)\tBlock %d (wei)\tDelta (wei)\t\n", from.Block, to.Block, from.Block, to.Block)
+	for _, mp := range minipoolDeltas {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", mp.Address.Hex(), mp.FromBranch, mp.ToBranch, mp.From, mp.To, mp.Delta)
+	}
+	w.Flush()
+
+	return nil
+
+}
+
+// Get the contribution of a component's change to the change in total ETH
+func getComponentDelta(name string, from *big.Int, to *big.Int) componentDelta {
+	return componentDelta{
+		Name:         name,
+		From:         from.String(),
+		To:           to.String(),
+		Contribution: big.NewInt(0).Sub(to, from).String(),
+	}
+}
+
+// Get the minipools whose user balance changed, sorted by the absolute size of the change
+func getMinipoolDeltas(from []minipoolBalanceDetails, to []minipoolBalanceDetails) []minipoolDelta {
+
+	fromByAddress := map[common.Address]minipoolBalanceDetails{}
+	for _, mp := range from {
+		fromByAddress[mp.Address] = mp
+	}
+
+	deltas := []minipoolDelta{}
+	seen := map[common.Address]bool{}
+	addDelta := func(address common.Address, fromMp *minipoolBalanceDetails, toMp *minipoolBalanceDetails) {
+		delta := minipoolDelta{
+			Address:    address,
+			FromBranch: "-",
+			ToBranch:   "-",
+		}
+		fromBalance := big.NewInt(0)
+		toBalance := big.NewInt(0)
+		if fromMp != nil {
+			delta.FromBranch = string(fromMp.Branch)
+			fromBalance = fromMp.UserBalance
+		}
+		if toMp != nil {
+			delta.ToBranch = string(toMp.Branch)
+			toBalance = toMp.UserBalance
+		}
+		delta.delta = big.NewInt(0).Sub(toBalance, fromBalance)
+		if delta.delta.Sign() == 0 {
+			return
+		}
+		delta.From = fromBalance.String()
+		delta.To = toBalance.String()
+		delta.Delta = delta.delta.String()
+		deltas = append(deltas, delta)
+	}
+
+	// Minipools at the end block, including new ones
+	for i := range to {
+		toMp := &to[i]
+		seen[toMp.Address] = true
+		if fromMp, exists := fromByAddress[toMp.Address]; exists {
+			addDelta(toMp.Address, &fromMp, toMp)
+		} else {
+			addDelta(toMp.Address, nil, toMp)
+		}
+	}
+
+	// Minipools that no longer exist at the end block
+	for i := range from {
+		if !seen[from[i].Address] {
+			addDelta(from[i].Address, &from[i], nil)
+		}
+	}
+
+	sort.SliceStable(deltas, func(i int, j int) bool {
+		return deltas[i].delta.CmpAbs(deltas[j].delta) > 0
+	})
+	return deltas
+
+}
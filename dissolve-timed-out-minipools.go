@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	rptypes "github.com/rocket-pool/rocketpool-go/types"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/services/state"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Dissolve timed out minipools task
+type dissolveTimedOutMinipools struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	ec           rocketpool.ExecutionClient
+	mgr          *stateManager
+	outputFormat string
+}
+
+// A prelaunch minipool that has exceeded the launch timeout
+type timedOutMinipool struct {
+	Address         common.Address `json:"address"`
+	NodeAddress     common.Address `json:"nodeAddress"`
+	StatusTime      time.Time      `json:"statusTime"`
+	TimeInPrelaunch string         `json:"timeInPrelaunch"`
+	Overdue         string         `json:"overdue"`
+	overdue         time.Duration
+}
+
+// Machine-readable timed out minipool report
+type dissolveTimedOutMinipoolsOutput struct {
+	ElBlock       uint64             `json:"elBlock"`
+	BeaconSlot    uint64             `json:"beaconSlot"`
+	BlockTime     time.Time          `json:"blockTime"`
+	LaunchTimeout string             `json:"launchTimeout"`
+	Minipools     []timedOutMinipool `json:"minipools"`
+}
+
+// Create dissolve timed out minipools task
+func newDissolveTimedOutMinipools(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*dissolveTimedOutMinipools, error) {
+
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ec, _, _, _, mgr, err := initialize(c, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing RP artifacts: %w", err)
+	}
+
+	// Return task
+	return &dissolveTimedOutMinipools{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		ec:           ec,
+		mgr:          mgr,
+		outputFormat: outputFormat,
+	}, nil
+
+}
+
+// Check for timed out minipools to dissolve
+func (t *dissolveTimedOutMinipools) run() error {
+
+	state, err := getTargetState(t.c, t.ec, t.mgr, t.log)
+	if err != nil {
+		return err
+	}
+
+	blockTime := getSlotTime(state.BeaconSlotNumber, state.BeaconConfig)
+	launchTimeout := time.Duration(state.NetworkDetails.MinipoolLaunchTimeout.Uint64()) * time.Second
+	minipools := getTimedOutMinipools(state, blockTime, launchTimeout)
+
+	// Print the results
+	t.log.Printlnf("Minipool launch timeout at EL block %d, CL slot %d (%s) is %s.", state.ElBlockNumber, state.BeaconSlotNumber, blockTime.UTC().Format(time.RFC3339), launchTimeout)
+	if len(minipools) == 0 {
+		t.log.Println("No minipools have timed out.")
+	} else {
+		t.log.Printlnf("%d minipool(s) have timed out and would be dissolved:", len(minipools))
+	}
+	for _, mp := range minipools {
+		t.errLog.Printlnf("\tMinipool %s (node %s): in prelaunch since %s (%s), overdue by %s", mp.Address.Hex(), mp.NodeAddress.Hex(), mp.StatusTime.Format(time.RFC3339), mp.TimeInPrelaunch, mp.Overdue)
+	}
+
+	// Print the machine-readable report
+	if t.outputFormat == outputFormatJson {
+		return printJson(dissolveTimedOutMinipoolsOutput{
+			ElBlock:       state.ElBlockNumber,
+			BeaconSlot:    state.BeaconSlotNumber,
+			BlockTime:     blockTime.UTC(),
+			LaunchTimeout: launchTimeout.String(),
+			Minipools:     minipools,
+		})
+	}
+	return nil
+
+}
+
+// Get the prelaunch minipools that have been in prelaunch for at least the launch timeout, the most overdue first
+func getTimedOutMinipools(state *state.NetworkState, blockTime time.Time, launchTimeout time.Duration) []timedOutMinipool {
+
+	minipools := []timedOutMinipool{}
+	for _, mpd := range state.MinipoolDetails {
+		if mpd.Status != rptypes.Prelaunch {
+			continue
+		}
+		statusTime := time.Unix(mpd.StatusTime.Int64(), 0)
+		timeInPrelaunch := blockTime.Sub(statusTime)
+		if timeInPrelaunch < launchTimeout {
+			continue
+		}
+		overdue := timeInPrelaunch - launchTimeout
+		minipools = append(minipools, timedOutMinipool{
+			Address:         mpd.MinipoolAddress,
+			NodeAddress:     mpd.NodeAddress,
+			StatusTime:      statusTime.UTC(),
+			TimeInPrelaunch: timeInPrelaunch.String(),
+			Overdue:         overdue.String(),
+			overdue:         overdue,
+		})
+	}
+
+	sort.SliceStable(minipools, func(i int, j int) bool {
+		return minipools[i].overdue > minipools[j].overdue
+	})
+	return minipools
+
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rocket-pool/rocketpool-go/rewards"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	"github.com/rocket-pool/smartnode/shared/services/config"
+	rprewards "github.com/rocket-pool/smartnode/shared/services/rewards"
+	"github.com/rocket-pool/smartnode/shared/services/state"
+	cfgtypes "github.com/rocket-pool/smartnode/shared/types/config"
+	"github.com/rocket-pool/smartnode/shared/utils/eth1"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Generate rewards tree task
+type generateRewardsTree struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	cfg          *config.RocketPoolConfig
+	ec           rocketpool.ExecutionClient
+	rp           *rocketpool.RocketPool
+	bc           beacon.Client
+	mgr          *stateManager
+	outputFormat string
+}
+
+// Machine-readable rewards tree generation report
+type generateRewardsTreeOutput struct {
+	Interval                    uint64 `json:"interval"`
+	ConsensusStartBlock         uint64 `json:"consensusStartBlock"`
+	ConsensusEndBlock           uint64 `json:"consensusEndBlock"`
+	ExecutionStartBlock         uint64 `json:"executionStartBlock"`
+	ExecutionEndBlock           uint64 `json:"executionEndBlock"`
+	MerkleRoot                  string `json:"merkleRoot"`
+	CanonicalMerkleRoot         string `json:"canonicalMerkleRoot"`
+	Matches                     bool   `json:"matches"`
+	RewardsFilePath             string `json:"rewardsFilePath"`
+	MinipoolPerformanceFilePath string `json:"minipoolPerformanceFilePath"`
+}
+
+// Create generate rewards tree task
+func newGenerateRewardsTree(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*generateRewardsTree, error) {
+
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ec, bc, rp, cfg, mgr, err := initialize(c, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing RP artifacts: %w", err)
+	}
+
+	// Return task
+	return &generateRewardsTree{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		cfg:          cfg,
+		ec:           ec,
+		rp:           rp,
+		bc:           bc,
+		mgr:          mgr,
+		outputFormat: outputFormat,
+	}, nil
+
+}
+
+// Generate the rewards tree
+func (t *generateRewardsTree) run() error {
+
+	if !t.c.IsSet("interval") {
+		return fmt.Errorf("interval must be provided")
+	}
+	index := t.c.Uint64("interval")
+
+	// Generate the tree
+	rewardsFile, rewardsEvent, err := t.generateTree(index)
+	if err != nil {
+		return err
+	}
+
+	// Validate the Merkle root
+	root := common.BytesToHash(rewardsFile.MerkleTree.Root())
+	matches := (root == rewardsEvent.MerkleRoot)
+	t.log.Printlnf("Merkle root: %s", root.Hex())
+	if matches {
+		t.log.Printlnf("This matches the canonical Merkle root of %s that the Oracle DAO submitted.", rewardsEvent.MerkleRoot.Hex())
+	} else {
+		t.errLog.Printlnf("WARNING: this does not match the canonical Merkle root of %s that the Oracle DAO submitted.", rewardsEvent.MerkleRoot.Hex())
+	}
+
+	// Serialize the files
+	rewardsFile.MinipoolPerformanceFileCID = "---"
+	minipoolPerformanceBytes, err := json.Marshal(rewardsFile.MinipoolPerformanceFile)
+	if err != nil {
+		return fmt.Errorf("error serializing minipool performance file into JSON: %w", err)
+	}
+	wrapperBytes, err := json.Marshal(rewardsFile)
+	if err != nil {
+		return fmt.Errorf("error serializing proof wrapper into JSON: %w", err)
+	}
+
+	// Write the files
+	network := string(t.cfg.Smartnode.Network.Value.(cfgtypes.Network))
+	outputDir := t.c.String("output-dir")
+	path := filepath.Join(outputDir, fmt.Sprintf(config.RewardsTreeFilenameFormat, network, index))
+	minipoolPerformancePath := filepath.Join(outputDir, fmt.Sprintf(config.MinipoolPerformanceFilenameFormat, network, index))
+	err = os.WriteFile(minipoolPerformancePath, minipoolPerformanceBytes, 0644)
+	if err != nil {
+		return fmt.Errorf("error saving minipool performance file to %s: %w", minipoolPerformancePath, err)
+	}
+	err = os.WriteFile(path, wrapperBytes, 0644)
+	if err != nil {
+		return fmt.Errorf("error saving rewards file to %s: %w", path, err)
+	}
+	t.log.Printlnf("Saved rewards file to %s.", path)
+	t.log.Printlnf("Saved minipool performance file to %s.", minipoolPerformancePath)
+
+	// Print the machine-readable report
+	if t.outputFormat == outputFormatJson {
+		err = printJson(generateRewardsTreeOutput{
+			Interval:                    index,
+			ConsensusStartBlock:         rewardsFile.ConsensusStartBlock,
+			ConsensusEndBlock:           rewardsFile.ConsensusEndBlock,
+			ExecutionStartBlock:         rewardsFile.ExecutionStartBlock,
+			ExecutionEndBlock:           rewardsFile.ExecutionEndBlock,
+			MerkleRoot:                  root.Hex(),
+			CanonicalMerkleRoot:         rewardsEvent.MerkleRoot.Hex(),
+			Matches:                     matches,
+			RewardsFilePath:             path,
+			MinipoolPerformanceFilePath: minipoolPerformancePath,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	t.log.Println("Merkle tree generation complete.")
+	return nil
+
+}
+
+// Generate the rewards tree for an interval, along with the rewards snapshot event the Oracle DAO submitted for it
+func (t *generateRewardsTree) generateTree(index uint64) (*rprewards.RewardsFile, rewards.RewardsEvent, error) {
+
+	generationPrefix := fmt.Sprintf("[Interval %d Tree]", index)
+	t.log.Printlnf("%s Starting generation of Merkle rewards tree for interval %d.", generationPrefix, index)
+
+	// Find the event for this interval
+	rewardsEvent, err := rprewards.GetRewardSnapshotEvent(t.rp, t.cfg, index)
+	if err != nil {
+		return nil, rewards.RewardsEvent{}, fmt.Errorf("error getting event for interval %d: %w", index, err)
+	}
+	t.log.Printlnf("%s Found snapshot event: Beacon block %s, execution block %s", generationPrefix, rewardsEvent.ConsensusBlock.String(), rewardsEvent.ExecutionBlock.String())
+
+	// Get the EL block
+	elBlockHeader, err := t.ec.HeaderByNumber(context.Background(), rewardsEvent.ExecutionBlock)
+	if err != nil {
+		return nil, rewards.RewardsEvent{}, fmt.Errorf("error getting execution block: %w", err)
+	}
+
+	// Get a client with the block number available
+	client, err := eth1.GetBestApiClient(t.rp, t.cfg, t.printMessage, elBlockHeader.Number)
+	if err != nil {
+		return nil, rewards.RewardsEvent{}, err
+	}
+
+	// Reuse the state manager unless a different client is required for this block, such as the archive EC for a past
+	// interval
+	mgr := t.mgr
+	if client != t.rp {
+		networkStateManager, err := state.NewNetworkStateManager(client, t.cfg, client.Client, t.bc, &t.log)
+		if err != nil {
+			return nil, rewards.RewardsEvent{}, fmt.Errorf("error creating network state manager for EL block %s: %w", elBlockHeader.Number, err)
+		}
+		mgr = &stateManager{
+			NetworkStateManager: networkStateManager,
+			ec:                  client.Client,
+			cache:               t.mgr.cache,
+		}
+	}
+
+	// Get the state for the target slot
+	state, err := mgr.GetStateForSlot(rewardsEvent.ConsensusBlock.Uint64())
+	if err != nil {
+		return nil, rewards.RewardsEvent{}, fmt.Errorf("error getting state for beacon slot %d: %w", rewardsEvent.ConsensusBlock.Uint64(), err)
+	}
+
+	// Generate the rewards file
+	start := time.Now()
+	treegen, err := rprewards.NewTreeGenerator(t.log, generationPrefix, client, t.cfg, t.bc, index, rewardsEvent.IntervalStartTime, rewardsEvent.IntervalEndTime, rewardsEvent.ConsensusBlock.Uint64(), elBlockHeader, rewardsEvent.IntervalsPassed.Uint64(), state)
+	if err != nil {
+		return nil, rewards.RewardsEvent{}, fmt.Errorf("error creating Merkle tree generator: %w", err)
+	}
+	rewardsFile, err := treegen.GenerateTree()
+	if err != nil {
+		return nil, rewards.RewardsEvent{}, fmt.Errorf("error generating Merkle tree: %w", err)
+	}
+	for address, network := range rewardsFile.InvalidNetworkNodes {
+		t.log.Printlnf("%s WARNING: Node %s has invalid network %d assigned! Using 0 (mainnet) instead.", generationPrefix, address.Hex(), network)
+	}
+	t.log.Printlnf("%s Finished in %s", generationPrefix, time.Since(start).String())
+
+	return rewardsFile, rewardsEvent, nil
+
+}
+
+// Prints a message to the log
+func (t *generateRewardsTree) printMessage(message string) {
+	t.log.Println(message)
+}
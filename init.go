@@ -1,38 +1,89 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"math/big"
+	"net/http"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/rocket-pool/rocketpool-go/rocketpool"
 	"github.com/rocket-pool/smartnode/shared/services/beacon"
 	"github.com/rocket-pool/smartnode/shared/services/beacon/client"
 	"github.com/rocket-pool/smartnode/shared/services/config"
 	"github.com/rocket-pool/smartnode/shared/services/state"
-	cfgtypes "github.com/rocket-pool/smartnode/shared/types/config"
 	"github.com/rocket-pool/smartnode/shared/utils/log"
 	"github.com/urfave/cli/v2"
 )
 
 // Initialize the common Rocket Pool artifacts necessary for Oracle DAO duty simulation
-func initialize(c *cli.Context, log log.ColorLogger) (rocketpool.ExecutionClient, beacon.Client, *rocketpool.RocketPool, *config.RocketPoolConfig, *state.NetworkStateManager, error) {
+func initialize(c *cli.Context, log log.ColorLogger) (rocketpool.ExecutionClient, beacon.Client, *rocketpool.RocketPool, *config.RocketPoolConfig, *stateManager, error) {
 
-	// URL acquisiton
+	// Snapshots are replayed without any clients, so only the commands that support them can use them
+	if c.IsSet("from-snapshot") {
+		return nil, nil, nil, nil, nil, fmt.Errorf("from-snapshot is only supported by submit-rpl-price and submit-network-balances")
+	}
+
+	// Set up recording or replaying of the client traffic if requested
+	traffic, err := newTrafficTransport(c)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	// URL acquisiton; replayed traffic doesn't depend on the endpoints, so they're optional then
 	ecUrl := c.String("ec-endpoint")
+	bnUrl := c.String("bn-endpoint")
+	if traffic != nil && traffic.mode == trafficModeReplay {
+		if ecUrl == "" {
+			ecUrl = trafficReplayUrl
+		}
+		if bnUrl == "" {
+			bnUrl = trafficReplayUrl
+		}
+	}
 	if ecUrl == "" {
 		return nil, nil, nil, nil, nil, fmt.Errorf("ec-endpoint must be provided")
 	}
-	bnUrl := c.String("bn-endpoint")
-	if ecUrl == "" {
+	if bnUrl == "" {
 		return nil, nil, nil, nil, nil, fmt.Errorf("bn-endpoint must be provided")
 	}
 
+	return initializeForEndpoints(c, log, ecUrl, bnUrl, traffic)
+
+}
+
+// Initialize the common Rocket Pool artifacts for a specific EC and BN, optionally recording or replaying their traffic
+func initializeForEndpoints(c *cli.Context, log log.ColorLogger, ecUrl string, bnUrl string, traffic *trafficTransport) (rocketpool.ExecutionClient, beacon.Client, *rocketpool.RocketPool, *config.RocketPoolConfig, *stateManager, error) {
+
 	// Create the EC and BN clients
-	ec, err := ethclient.Dial(ecUrl)
+	var ec *ethclient.Client
+	var err error
+	if traffic == nil {
+		ec, err = ethclient.Dial(ecUrl)
+	} else {
+		ec, err = dialRecordedEc(ecUrl, traffic.forService("ec"))
+	}
 	if err != nil {
 		return nil, nil, nil, nil, nil, fmt.Errorf("error connecting to the EC: %w", err)
 	}
+	if traffic != nil {
+		// The BN client always uses the default HTTP client, so its traffic can only be captured by replacing the
+		// default client's transport. This affects every later request in the process that uses the default client
+		// and isn't undone, so the transport only handles requests to the BN and sends the rest on normally.
+		bnTransport, err := traffic.forServiceUrl("bn", bnUrl)
+		if err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
+		http.DefaultClient.Transport = bnTransport
+		if traffic.mode == trafficModeReplay {
+			log.Printlnf("Replaying EC and BN traffic from %s.", traffic.dir)
+		} else {
+			log.Printlnf("Recording EC and BN traffic to %s.", traffic.dir)
+		}
+	}
 	bc := client.NewStandardHttpClient(bnUrl)
 
 	// Check which network we're on via the BN
@@ -40,24 +91,18 @@ func initialize(c *cli.Context, log log.ColorLogger) (rocketpool.ExecutionClient
 	if err != nil {
 		return nil, nil, nil, nil, nil, fmt.Errorf("error getting deposit contract from the BN: %w", err)
 	}
-	var network cfgtypes.Network
-	switch depositContract.ChainID {
-	case 1:
-		network = cfgtypes.Network_Mainnet
-		log.Printlnf("Beacon node is configured for Mainnet.")
-	case 5:
-		network = cfgtypes.Network_Prater
-		log.Printlnf("Beacon node is configured for Prater.")
-	case 1337803:
-		network = cfgtypes.Network_Zhejiang
-		log.Printlnf("Beacon node is configured for Zhejiang.")
-	default:
-		return nil, nil, nil, nil, nil, fmt.Errorf("your Beacon node is configured for an unknown network with Chain ID [%d]", depositContract.ChainID)
+	network, err := getNetwork(c, depositContract.ChainID, log)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
 	}
 
 	// Create a new config on the proper network
 	cfg := config.NewRocketPoolConfig("", true)
 	cfg.Smartnode.Network.Value = network
+	err = applyNetworkOverrides(c, cfg, depositContract.ChainID, log)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
 
 	// Create the RP wrapper
 	storageContract := cfg.Smartnode.GetStorageAddress()
@@ -65,13 +110,80 @@ func initialize(c *cli.Context, log log.ColorLogger) (rocketpool.ExecutionClient
 	if err != nil {
 		return nil, nil, nil, nil, nil, fmt.Errorf("error creating Rocket Pool wrapper: %w", err)
 	}
+	err = resolveRethAddress(c, cfg, rp, log)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
 
-	// Create the state manager
-	mgr, err := state.NewNetworkStateManager(rp, cfg, rp.Client, bc, &log)
+	// Create the state manager, using the state cache if requested
+	cache, err := newStateCache(c, log)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+	networkStateManager, err := state.NewNetworkStateManager(rp, cfg, rp.Client, bc, &log)
 	if err != nil {
 		return nil, nil, nil, nil, nil, err
 	}
+	mgr := &stateManager{
+		NetworkStateManager: networkStateManager,
+		ec:                  ec,
+		cache:               cache,
+	}
 
 	return ec, bc, rp, cfg, mgr, nil
 
 }
+
+// Get the Beacon slot corresponding to the timestamp of an EL block
+func getBeaconSlotForBlock(header *types.Header, eth2Config beacon.Eth2Config) uint64 {
+	blockTime := time.Unix(int64(header.Time), 0)
+	genesisTime := time.Unix(int64(eth2Config.GenesisTime), 0)
+	timeSinceGenesis := blockTime.Sub(genesisTime)
+	return uint64(timeSinceGenesis.Seconds()) / eth2Config.SecondsPerSlot
+}
+
+// Get the time of a Beacon slot
+func getSlotTime(slot uint64, eth2Config beacon.Eth2Config) time.Time {
+	genesisTime := time.Unix(int64(eth2Config.GenesisTime), 0)
+	return genesisTime.Add(time.Duration(slot*eth2Config.SecondsPerSlot) * time.Second)
+}
+
+// Get the network state for the target block, or for the chain head if a target block wasn't provided
+func getTargetState(c *cli.Context, ec rocketpool.ExecutionClient, mgr *stateManager, log log.ColorLogger) (*state.NetworkState, error) {
+
+	if !c.IsSet("target-block") {
+		log.Printlnf("Target block not set, getting the state of the chain head.")
+		state, err := mgr.GetHeadState()
+		if err != nil {
+			return nil, fmt.Errorf("error getting network state for head slot: %w", err)
+		}
+		return state, nil
+	}
+
+	// Get the time of the block
+	blockNumber := c.Uint64("target-block")
+	header, err := ec.HeaderByNumber(context.Background(), big.NewInt(0).SetUint64(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the Beacon block corresponding to this time
+	slotNumber := getBeaconSlotForBlock(header, mgr.BeaconConfig)
+	state, err := mgr.GetStateForSlot(slotNumber)
+	if err != nil {
+		return nil, fmt.Errorf("error getting state for EL block %d, CL slot %d: %w", blockNumber, slotNumber, err)
+	}
+	return state, nil
+
+}
+
+// Get the EL block a duty should be simulated for. By default this is the latest block the Oracle DAO could report for
+// as of the target block, just like the watchtower would use; if that's disabled, the target block is used directly.
+func getDutyBlock(c *cli.Context, log log.ColorLogger, targetBlock uint64, reportableBlock uint64) uint64 {
+	if !c.Bool("reportable-block") {
+		log.Printlnf("Using target block %d directly (reportable block resolution is disabled).", targetBlock)
+		return targetBlock
+	}
+	log.Printlnf("Target block is %d, latest reportable block is %d.", targetBlock, reportableBlock)
+	return reportableBlock
+}
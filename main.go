@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/rocket-pool/smartnode/shared/utils/log"
@@ -50,12 +51,69 @@ func main() {
 			Usage:   "The URL of the Beacon Node's REST API. Note that for past interval generation, this must have Archive capability (ability to replay arbitrary historical states).",
 			Value:   "http://localhost:5052",
 		},
+		&cli.StringFlag{
+			Name:    "network",
+			Aliases: []string{"n"},
+			Usage:   "(Optional) the network settings to use (mainnet, prater, zhejiang or devnet) instead of detecting them from the BN's chain ID. Use devnet with the address overrides below for Holesky, local devnets, forks or other custom deployments.",
+		},
+		&cli.StringFlag{
+			Name:  "storage-address",
+			Usage: "(Optional) override the address of the RocketStorage contract. Unless reth-address is also set, the rETH address is looked up from it.",
+		},
+		&cli.StringFlag{
+			Name:  "reth-address",
+			Usage: "(Optional) override the address of the rETH token contract",
+		},
+		&cli.StringFlag{
+			Name:  "rpl-twap-pool-address",
+			Usage: "(Optional) override the address of the Uniswap v3 RPL/ETH pool used for the RPL price TWAP",
+		},
+		&cli.StringFlag{
+			Name:  "multicall-address",
+			Usage: "(Optional) override the address of the Multicall contract used to load the network state",
+		},
+		&cli.StringFlag{
+			Name:  "balance-batcher-address",
+			Usage: "(Optional) override the address of the balance batcher contract used to load the network state",
+		},
 		&cli.Uint64Flag{
 			Name:    "target-block",
 			Aliases: []string{"t"},
 			Usage:   "(Optional) the EL block to target for duties (default is the chain head if this is omitted)",
 			Value:   0,
 		},
+		&cli.BoolFlag{
+			Name:    "reportable-block",
+			Aliases: []string{"r"},
+			Usage:   "Simulate duties for the latest block the Oracle DAO could report for as of the target block (like the watchtower does), instead of the target block itself. Use --reportable-block=false to disable.",
+			Value:   true,
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "The format to print results in: 'text' for log output only, or 'json' to also print a machine-readable document to stdout",
+			Value:   "text",
+		},
+		&cli.StringFlag{
+			Name:  "cache-dir",
+			Usage: "(Optional) a directory to save loaded network states in, so later runs for the same slot can reuse them instead of loading them again",
+		},
+		&cli.StringFlag{
+			Name:  "from-snapshot",
+			Usage: "(Optional) a snapshot file from 'snapshot capture' to replay submit-rpl-price and submit-network-balances from, without an EC or BN",
+		},
+		&cli.StringFlag{
+			Name:  "config",
+			Usage: "(Optional) a YAML or TOML file to read options from. Global options are top-level keys, and command options are in a section named after the command. Options on the command line or in ODAOTOOL_* environment variables take precedence.",
+		},
+		&cli.StringFlag{
+			Name:  "record",
+			Usage: "(Optional) a directory to record every EC and BN request and response in, so the run can be repeated later with --replay",
+		},
+		&cli.StringFlag{
+			Name:  "replay",
+			Usage: "(Optional) a directory of traffic recorded with --record to serve EC and BN responses from, instead of contacting them",
+		},
 	}
 
 	// Set commands
@@ -66,7 +124,12 @@ func main() {
 		UsageText: "odaotool submit-rpl-price",
 		Action: func(c *cli.Context) error {
 
-			submitRplPrice, err := newSubmitRplPrice(c, logger, errorLogger)
+			// Only the submit commands can replay a snapshot; the others are rejected by initialize
+			newTask := newSubmitRplPrice
+			if c.IsSet("from-snapshot") {
+				newTask = newSubmitRplPriceFromSnapshot
+			}
+			submitRplPrice, err := newTask(c, logger, errorLogger)
 			if err != nil {
 				return err
 			}
@@ -79,10 +142,24 @@ func main() {
 			Name:      "submit-network-balances",
 			Aliases:   []string{"b"},
 			Usage:     "Simulate submitting the network balances",
-			UsageText: "odaotool submit-network-balances",
+			UsageText: "odaotool submit-network-balances [options]",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "breakdown",
+					Usage: "(Optional) a CSV file to write the balance details of each minipool to, including which rule was used to calculate its user balance",
+				},
+				&cli.StringFlag{
+					Name:  "node-breakdown",
+					Usage: "(Optional) a CSV file to write the fee distributor user share and deposit credit of each node to",
+				},
+			},
 			Action: func(c *cli.Context) error {
 
-				submitNetworkBalances, err := newSubmitNetworkBalances(c, logger, errorLogger)
+				newTask := newSubmitNetworkBalances
+				if c.IsSet("from-snapshot") {
+					newTask = newSubmitNetworkBalancesFromSnapshot
+				}
+				submitNetworkBalances, err := newTask(c, logger, errorLogger)
 				if err != nil {
 					return err
 				}
@@ -91,18 +168,520 @@ func main() {
 
 			},
 		},
+		&cli.Command{
+			Name:      "verify-network-balances",
+			Aliases:   []string{"vb"},
+			Usage:     "Compare the network balances last reported on-chain with a simulation at the reported block",
+			UsageText: "odaotool verify-network-balances [options]",
+			Flags: []cli.Flag{
+				&cli.Float64Flag{
+					Name:  "tolerance",
+					Usage: "The maximum allowed relative deviation between the reported and simulated values (e.g. 0.0001 for 0.01%) before exiting with an error",
+					Value: 0,
+				},
+			},
+			Action: func(c *cli.Context) error {
+
+				verifyNetworkBalances, err := newVerifyNetworkBalances(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return verifyNetworkBalances.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "verify-rpl-price",
+			Aliases:   []string{"vp"},
+			Usage:     "Compare the RPL price last reported on-chain with a TWAP recomputation at the reported block",
+			UsageText: "odaotool verify-rpl-price [options]",
+			Flags: []cli.Flag{
+				&cli.Float64Flag{
+					Name:  "tolerance",
+					Usage: "The maximum allowed relative deviation between the reported and simulated price (e.g. 0.0001 for 1 bps) before exiting with an error",
+					Value: 0,
+				},
+			},
+			Action: func(c *cli.Context) error {
+
+				verifyRplPrice, err := newVerifyRplPrice(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return verifyRplPrice.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "backfill",
+			Aliases:   []string{"bf"},
+			Usage:     "Simulate the RPL price and network balances over a range of blocks, writing one row per point to a CSV or JSONL file",
+			UsageText: "odaotool backfill [options]",
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:  "from-block",
+					Usage: "The first EL block of the range",
+				},
+				&cli.Uint64Flag{
+					Name:  "to-block",
+					Usage: "The last EL block of the range (default is the chain head if neither this nor to-date is provided)",
+				},
+				&cli.StringFlag{
+					Name:  "from-date",
+					Usage: "The start of the range as a date (YYYY-MM-DD or RFC3339), instead of from-block",
+				},
+				&cli.StringFlag{
+					Name:  "to-date",
+					Usage: "The (exclusive) end of the range as a date (YYYY-MM-DD or RFC3339), instead of to-block",
+				},
+				&cli.Uint64Flag{
+					Name:  "step",
+					Usage: "Run a point every N blocks, starting at the first block of the range",
+				},
+				&cli.BoolFlag{
+					Name:  "step-interval",
+					Usage: "Run a point at every balance reporting checkpoint in the range, instead of using step",
+				},
+				&cli.StringFlag{
+					Name:    "output-file",
+					Aliases: []string{"f"},
+					Usage:   "The file to write the rows to. If it already has rows, the backfill resumes after the last one.",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "The format of the output file, 'csv' or 'jsonl' (default is based on the output file's extension)",
+				},
+				&cli.IntFlag{
+					Name:  "workers",
+					Usage: "The number of points to run concurrently",
+					Value: 4,
+				},
+			},
+			Action: func(c *cli.Context) error {
+
+				backfill, err := newBackfill(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return backfill.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "diff-balances",
+			Aliases:   []string{"db"},
+			Usage:     "Explain the change in network balances and the rETH ratio between two blocks",
+			UsageText: "odaotool diff-balances --from <block> --to <block> [options]",
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:  "from",
+					Usage: "The EL block to compare from",
+				},
+				&cli.Uint64Flag{
+					Name:  "to",
+					Usage: "The EL block to compare to",
+				},
+				&cli.IntFlag{
+					Name:  "top",
+					Usage: "The number of minipools with the largest change in user balance to show",
+					Value: 10,
+				},
+			},
+			Action: func(c *cli.Context) error {
+
+				diffBalances, err := newDiffBalances(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return diffBalances.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "generate-rewards-tree",
+			Aliases:   []string{"g"},
+			Usage:     "Simulate generating the rewards Merkle tree for a past interval",
+			UsageText: "odaotool generate-rewards-tree --interval <index> [options]",
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:    "interval",
+					Aliases: []string{"i"},
+					Usage:   "The rewards interval to generate the tree for",
+				},
+				&cli.StringFlag{
+					Name:  "output-dir",
+					Usage: "The directory to save the rewards file and minipool performance file to",
+					Value: ".",
+				},
+			},
+			Action: func(c *cli.Context) error {
+
+				generateRewardsTree, err := newGenerateRewardsTree(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return generateRewardsTree.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "verify-rewards-tree",
+			Aliases:   []string{"vt"},
+			Usage:     "Verify a rewards file against the Merkle root recorded on-chain for its interval",
+			UsageText: "odaotool verify-rewards-tree --file <rewards file> [options]",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "file",
+					Aliases: []string{"f"},
+					Usage:   "The rewards file to verify",
+				},
+				&cli.Uint64Flag{
+					Name:    "interval",
+					Aliases: []string{"i"},
+					Usage:   "(Optional) the rewards interval the file is expected to be for",
+				},
+				&cli.BoolFlag{
+					Name:  "regenerate",
+					Usage: "If the roots don't match, regenerate the tree from chain data and show which nodes have different rewards",
+				},
+			},
+			Action: func(c *cli.Context) error {
+
+				verifyRewardsTree, err := newVerifyRewardsTree(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return verifyRewardsTree.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "scrub-minipools",
+			Aliases:   []string{"s"},
+			Usage:     "Simulate the minipool scrub check and list the prelaunch minipools that would be scrubbed",
+			UsageText: "odaotool scrub-minipools [options]",
+			Action: func(c *cli.Context) error {
+
+				scrubMinipools, err := newScrubMinipools(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return scrubMinipools.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "dissolve-timed-out-minipools",
+			Aliases:   []string{"d"},
+			Usage:     "Simulate dissolving prelaunch minipools that have exceeded the launch timeout",
+			UsageText: "odaotool dissolve-timed-out-minipools [options]",
+			Action: func(c *cli.Context) error {
+
+				dissolveTimedOutMinipools, err := newDissolveTimedOutMinipools(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return dissolveTimedOutMinipools.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "check-penalties",
+			Aliases:   []string{"cp"},
+			Usage:     "Simulate the fee recipient penalty check for the blocks in a range of slots",
+			UsageText: "odaotool check-penalties --from-slot <slot> [--to-slot <slot>] [options]",
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:  "from-slot",
+					Usage: "The first Beacon slot to check",
+				},
+				&cli.Uint64Flag{
+					Name:  "to-slot",
+					Usage: "The last Beacon slot to check (default is the latest finalized slot)",
+				},
+				&cli.IntFlag{
+					Name:  "workers",
+					Usage: "The number of slots to check concurrently",
+					Value: 4,
+				},
+			},
+			Action: func(c *cli.Context) error {
+
+				checkPenalties, err := newCheckPenalties(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return checkPenalties.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "check-bond-reductions",
+			Aliases:   []string{"cb"},
+			Usage:     "Simulate the bond reduction check and list the pending bond reductions that would be cancelled",
+			UsageText: "odaotool check-bond-reductions [options]",
+			Action: func(c *cli.Context) error {
+
+				checkBondReductions, err := newCheckBondReductions(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return checkBondReductions.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "check-solo-migrations",
+			Aliases:   []string{"cs"},
+			Usage:     "Simulate the solo validator migration check and list the vacant minipools that would be scrubbed",
+			UsageText: "odaotool check-solo-migrations [options]",
+			Action: func(c *cli.Context) error {
+
+				checkSoloMigrations, err := newCheckSoloMigrations(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return checkSoloMigrations.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "run-all",
+			Aliases:   []string{"a"},
+			Usage:     "Load the network state once and simulate every duty against it. The state is for the latest reportable balances block as of the target block, or for the target block itself with --reportable-block=false",
+			UsageText: "odaotool run-all [options]",
+			Action: func(c *cli.Context) error {
+
+				runAll, err := newRunAll(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return runAll.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "watch",
+			Aliases:   []string{"w"},
+			Usage:     "Run continuously, simulating the price and balance duties whenever a new reportable block is finalized",
+			UsageText: "odaotool watch [options]",
+			Flags: []cli.Flag{
+				&cli.DurationFlag{
+					Name:  "poll-interval",
+					Usage: "How often to check the Beacon node for a new finalized block",
+					Value: time.Minute,
+				},
+				&cli.StringFlag{
+					Name:    "output-file",
+					Aliases: []string{"f"},
+					Usage:   "A JSONL file to append each result to",
+				},
+				&cli.StringFlag{
+					Name:    "metrics-address",
+					Aliases: []string{"m"},
+					Usage:   "An address (e.g. ':9102') to serve the latest results on as Prometheus metrics, at /metrics. Also compares each report the Oracle DAO submits on-chain with its simulation.",
+				},
+				&cli.BoolFlag{
+					Name:  "every-finalized-block",
+					Usage: "Simulate the price and balance duties for every new finalized block, instead of only for new reportable blocks",
+				},
+			},
+			Action: func(c *cli.Context) error {
+
+				watch, err := newWatch(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return watch.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "serve",
+			Aliases:   []string{"sv"},
+			Usage:     "Run an HTTP server that simulates the price and balance duties on demand, at GET /rpl-price?block=N and GET /network-balances?block=N",
+			UsageText: "odaotool serve [options]",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "listen-address",
+					Aliases: []string{"l"},
+					Usage:   "The address to serve the API on",
+					Value:   "127.0.0.1:8090",
+				},
+				&cli.IntFlag{
+					Name:  "max-state-loads",
+					Usage: "The maximum number of network states to load at the same time; further balance requests wait for one to finish",
+					Value: 1,
+				},
+				&cli.IntFlag{
+					Name:  "cache-size",
+					Usage: "The number of blocks to keep the results of for each endpoint; the lowest blocks are dropped first",
+					Value: 1000,
+				},
+			},
+			Action: func(c *cli.Context) error {
+
+				serve, err := newServe(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return serve.run()
+
+			},
+		},
+		&cli.Command{
+			Name:      "cross-check",
+			Aliases:   []string{"xc"},
+			Usage:     "Simulate the price and balance duties with several EC/BN pairs at the same target block, and report where their results differ",
+			UsageText: "odaotool [global options] cross-check -e <name>=<ec url> -b <name>=<bn url> -e <name>=<ec url> -b <name>=<bn url> [options]",
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:    "ec-endpoint",
+					Aliases: []string{"e"},
+					Usage:   "An EC to check, as <name>=<url> or just <url> to pair it with the BN in the same position. Give this once per pair, or once without a name to share one EC between every pair. This shadows the global ec-endpoint option, which cross-check ignores along with ODAOTOOL_EC_ENDPOINT; use ODAOTOOL_CROSS_CHECK_EC_ENDPOINT instead.",
+				},
+				&cli.StringSliceFlag{
+					Name:    "bn-endpoint",
+					Aliases: []string{"b"},
+					Usage:   "A BN to check, as <name>=<url> or just <url> to pair it with the EC in the same position. Give this once per pair, or once without a name to share one BN between every pair. This shadows the global bn-endpoint option, which cross-check ignores along with ODAOTOOL_BN_ENDPOINT; use ODAOTOOL_CROSS_CHECK_BN_ENDPOINT instead.",
+				},
+				&cli.IntFlag{
+					Name:  "top",
+					Usage: "The number of minipools with the largest difference in user balance to show for each pair",
+					Value: 10,
+				},
+			},
+			Action: func(c *cli.Context) error {
+
+				crossCheck, err := newCrossCheck(c, logger, errorLogger)
+				if err != nil {
+					return err
+				}
+
+				return crossCheck.run()
+
+			},
+		},
+		&cli.Command{
+			Name:    "cache",
+			Aliases: []string{"ca"},
+			Usage:   "Manage the network state cache in --cache-dir",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "prune",
+					Aliases:   []string{"p"},
+					Usage:     "Remove unreadable or outdated snapshots and leftover temporary files from the cache",
+					UsageText: "odaotool --cache-dir <dir> cache prune [options]",
+					Flags: []cli.Flag{
+						&cli.DurationFlag{
+							Name:  "max-age",
+							Usage: "Also remove snapshots created longer ago than this (e.g. 720h)",
+						},
+						&cli.BoolFlag{
+							Name:  "all",
+							Usage: "Remove every snapshot",
+						},
+					},
+					Action: func(c *cli.Context) error {
+
+						pruneCache, err := newPruneCache(c, logger, errorLogger)
+						if err != nil {
+							return err
+						}
+
+						return pruneCache.run()
+
+					},
+				},
+			},
+		},
+		&cli.Command{
+			Name:    "snapshot",
+			Aliases: []string{"sn"},
+			Usage:   "Manage portable snapshots of the data the price and balance duties need",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "capture",
+					Aliases:   []string{"c"},
+					Usage:     "Capture the network state, block header, TWAP pool observation and Beacon config for a target block into a file that can be replayed with --from-snapshot",
+					UsageText: "odaotool snapshot capture --target-block <block> [options]",
+					Flags: []cli.Flag{
+						&cli.Uint64Flag{
+							Name:    "target-block",
+							Aliases: []string{"t"},
+							Usage:   "The EL block to capture",
+						},
+						&cli.StringFlag{
+							Name:    "file",
+							Aliases: []string{"f"},
+							Usage:   "The file to write the snapshot to (default is snapshot-<network>-<block>.gob.gz)",
+						},
+					},
+					Action: func(c *cli.Context) error {
+
+						captureSnapshot, err := newCaptureSnapshot(c, logger, errorLogger)
+						if err != nil {
+							return err
+						}
+
+						return captureSnapshot.run()
+
+					},
+				},
+			},
+		},
+		&cli.Command{
+			Name:    "config",
+			Aliases: []string{"cf"},
+			Usage:   "Inspect the configuration from the command line, ODAOTOOL_* environment variables and --config file",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "show",
+					Aliases:   []string{"s"},
+					Usage:     "Print the effective value and source of every global option, and the command options set by environment variables or the config file",
+					UsageText: "odaotool [global options] config show",
+					Action: func(c *cli.Context) error {
+
+						showConfig, err := newShowConfig(c, logger, errorLogger)
+						if err != nil {
+							return err
+						}
+
+						return showConfig.run()
+
+					},
+				},
+			},
+		},
 	)
 
+	// Let options be set by environment variables and the config file
+	initConfigSources(app)
+
 	// Allow lots of simultaneous connections
 	http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost = 200
 
-	// Run application
-	fmt.Println("")
+	// Run application; stdout is reserved for the JSON output, so everything else goes to stderr
+	fmt.Fprintln(os.Stderr, "")
 	err := app.Run(os.Args)
 	if err != nil {
-		fmt.Printf("%sError during execution: %s%s\n", colorRed, err.Error(), colorReset)
+		fmt.Fprintf(os.Stderr, "%sError during execution: %s%s\n", colorRed, err.Error(), colorReset)
 		os.Exit(1)
 	}
-	fmt.Println("")
+	fmt.Fprintln(os.Stderr, "")
 
 }
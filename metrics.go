@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rocket-pool/rocketpool-go/utils/eth"
+
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+const metricsNamespace string = "odaotool"
+
+// Prometheus metrics for the simulated duty results
+type dutyMetrics struct {
+	registry *prometheus.Registry
+	server   *http.Server
+
+	rplPrice          prometheus.Gauge
+	rplPriceBlock     prometheus.Gauge
+	networkBalances   *prometheus.GaugeVec
+	balancesBlock     prometheus.Gauge
+	rethRatio         prometheus.Gauge
+	deviation         *prometheus.GaugeVec
+	stateLoadDuration *prometheus.GaugeVec
+	errors            *prometheus.CounterVec
+}
+
+// Create the metrics and start serving them on the provided address
+func newDutyMetrics(address string, logger log.ColorLogger, errorLogger log.ColorLogger) (*dutyMetrics, error) {
+
+	m := &dutyMetrics{
+		registry: prometheus.NewRegistry(),
+		rplPrice: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "rpl_price_eth",
+			Help:      "The latest simulated RPL price, in ETH",
+		}),
+		rplPriceBlock: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "rpl_price_block",
+			Help:      "The EL block of the latest simulated RPL price",
+		}),
+		networkBalances: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "network_balance_eth",
+			Help:      "The latest simulated network balances, in ETH",
+		}, []string{"component"}),
+		balancesBlock: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "network_balances_block",
+			Help:      "The EL block of the latest simulated network balances",
+		}),
+		rethRatio: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "reth_ratio",
+			Help:      "The latest simulated rETH ratio",
+		}),
+		deviation: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "deviation_ratio",
+			Help:      "The relative deviation of the latest simulated value from the value reported on-chain for the same block",
+		}, []string{"value"}),
+		stateLoadDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "state_load_duration_seconds",
+			Help:      "How long the latest simulation took to load its network state",
+		}, []string{"duty"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "errors_total",
+			Help:      "The number of failed simulations",
+		}, []string{"duty"}),
+	}
+	m.registry.MustRegister(m.rplPrice, m.rplPriceBlock, m.networkBalances, m.balancesBlock, m.rethRatio, m.deviation, m.stateLoadDuration, m.errors)
+
+	// Start the HTTP server
+	metricsPath := "/metrics"
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html>
+			<head><title>odaotool Metrics Exporter</title></head>
+			<body>
+			<h1>odaotool Metrics Exporter</h1>
+			<p><a href='` + metricsPath + `'>Metrics</a></p>
+			</body>
+			</html>`,
+		))
+	})
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s: %w", address, err)
+	}
+	m.server = &http.Server{
+		Handler: mux,
+	}
+	logger.Printlnf("Started metrics exporter on %s.", listener.Addr())
+	go func() {
+		err := m.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errorLogger.Printlnf("Error running metrics server: %s", err.Error())
+		}
+	}()
+
+	return m, nil
+
+}
+
+// Stop serving the metrics
+func (m *dutyMetrics) close() error {
+	if m == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := m.server.Shutdown(ctx)
+	if err != nil {
+		return fmt.Errorf("error stopping metrics server: %w", err)
+	}
+	return nil
+}
+
+// Record a simulated RPL price
+func (m *dutyMetrics) setRplPrice(price *rplPriceOutput) {
+	if m == nil {
+		return
+	}
+	m.rplPrice.Set(price.PriceEth)
+	m.rplPriceBlock.Set(float64(price.Block))
+}
+
+// Record a set of simulated network balances
+func (m *dutyMetrics) setNetworkBalances(balances networkBalances) {
+	if m == nil {
+		return
+	}
+	components := map[string]float64{
+		"deposit_pool":         eth.WeiToEth(balances.DepositPool),
+		"minipools_total":      eth.WeiToEth(balances.MinipoolsTotal),
+		"minipools_staking":    eth.WeiToEth(balances.MinipoolsStaking),
+		"distributor_share":    eth.WeiToEth(balances.DistributorShareTotal),
+		"smoothing_pool_share": eth.WeiToEth(balances.SmoothingPoolShare),
+		"reth_contract":        eth.WeiToEth(balances.RETHContract),
+		"node_credit":          eth.WeiToEth(balances.NodeCreditBalance),
+		"reth_supply":          eth.WeiToEth(balances.RETHSupply),
+		"total_eth":            eth.WeiToEth(balances.getTotalEth()),
+	}
+	for component, value := range components {
+		m.networkBalances.WithLabelValues(component).Set(value)
+	}
+	m.balancesBlock.Set(float64(balances.Block))
+	m.rethRatio.Set(balances.getRETHRatio())
+	m.stateLoadDuration.WithLabelValues("balances").Set(balances.StateLoadTime.Seconds())
+}
+
+// Record the deviation of a simulated value from the one reported on-chain
+func (m *dutyMetrics) setDeviation(value string, deviation valueDeviation) {
+	if m == nil {
+		return
+	}
+	m.deviation.WithLabelValues(value).Set(deviation.Relative)
+}
+
+// Record a failed simulation
+func (m *dutyMetrics) addError(duty string) {
+	if m == nil {
+		return
+	}
+	m.errors.WithLabelValues(duty).Inc()
+}
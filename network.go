@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/services/config"
+	cfgtypes "github.com/rocket-pool/smartnode/shared/types/config"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// A contract address that can be overridden for custom deployments
+type addressOverride struct {
+	// The name of the flag that sets it
+	flag string
+
+	// The name of the per-network address map in the Smartnode config that it replaces
+	field string
+}
+
+// The contract addresses that can be overridden. These are all the per-network addresses in the Smartnode config that
+// odaotool's commands read; the others (such as the RPL token, the L2 price messengers and the v1.0.0 and v1.1.0
+// legacy contracts) are only used by the Smartnode's node and wallet commands.
+var addressOverrides = []addressOverride{
+	{flag: "storage-address", field: "storageAddress"},
+	{flag: "reth-address", field: "rethAddress"},
+	{flag: "rpl-twap-pool-address", field: "rplTwapPoolAddress"},
+	{flag: "multicall-address", field: "multicallAddress"},
+	{flag: "balance-batcher-address", field: "balancebatcherAddress"},
+}
+
+// Get the network to use, either from the --network option or by the chain ID the BN is configured for
+func getNetwork(c *cli.Context, chainID uint64, log log.ColorLogger) (cfgtypes.Network, error) {
+
+	if c.IsSet("network") {
+		network := cfgtypes.Network(c.String("network"))
+		switch network {
+		case cfgtypes.Network_Mainnet, cfgtypes.Network_Prater, cfgtypes.Network_Zhejiang, cfgtypes.Network_Devnet:
+		default:
+			return cfgtypes.Network_Unknown, fmt.Errorf("unknown network [%s]; use mainnet, prater, zhejiang, or devnet with address overrides for a custom deployment", network)
+		}
+		log.Printlnf("Using the %s network settings for chain ID %d.", network, chainID)
+		return network, nil
+	}
+
+	switch chainID {
+	case 1:
+		log.Printlnf("Beacon node is configured for Mainnet.")
+		return cfgtypes.Network_Mainnet, nil
+	case 5:
+		log.Printlnf("Beacon node is configured for Prater.")
+		return cfgtypes.Network_Prater, nil
+	case 1337803:
+		log.Printlnf("Beacon node is configured for Zhejiang.")
+		return cfgtypes.Network_Zhejiang, nil
+	default:
+		return cfgtypes.Network_Unknown, fmt.Errorf("your Beacon node is configured for an unknown network with Chain ID [%d]; use --network devnet with address overrides for a custom deployment", chainID)
+	}
+
+}
+
+// Apply the chain ID and any contract address overrides to the config of the selected network
+func applyNetworkOverrides(c *cli.Context, cfg *config.RocketPoolConfig, chainID uint64, log log.ColorLogger) error {
+
+	network := cfg.Smartnode.Network.Value.(cfgtypes.Network)
+	if c.IsSet("network") {
+		err := setSmartnodeValue(cfg, "chainID", network, uint(chainID))
+		if err != nil {
+			return err
+		}
+	}
+
+	// The rewards history of the built-in networks doesn't apply to a custom deployment, so its rewards intervals are
+	// found from its own events, starting at its deployment block
+	if c.IsSet("storage-address") {
+		if network == cfgtypes.Network_Prater {
+			return fmt.Errorf("the prater settings can't be used with storage-address, since its first rewards intervals use legacy contracts that can't be overridden; use --network devnet for a custom deployment")
+		}
+		err := setSmartnodeValue(cfg, "previousRewardsPoolAddresses", network, map[string][]common.Address{})
+		if err != nil {
+			return err
+		}
+		err = setSmartnodeValue(cfg, "rewardsSubmissionBlockMaps", network, []uint64{})
+		if err != nil {
+			return err
+		}
+		log.Println("Looking up rewards intervals from the custom deployment's events instead of the built-in submission blocks.")
+	}
+
+	for _, override := range addressOverrides {
+		if !c.IsSet(override.flag) {
+			continue
+		}
+		address := c.String(override.flag)
+		if !common.IsHexAddress(address) {
+			return fmt.Errorf("%s [%s] is not a valid address", override.flag, address)
+		}
+		err := setSmartnodeValue(cfg, override.field, network, common.HexToAddress(address).Hex())
+		if err != nil {
+			return err
+		}
+		log.Printlnf("Using %s %s.", override.flag, common.HexToAddress(address).Hex())
+	}
+	return nil
+
+}
+
+// Look up the rETH address of a custom RocketStorage deployment, unless it was provided explicitly. The Smartnode
+// checks the rETH address reported by the EC against the config before using it, so it has to match the deployment.
+func resolveRethAddress(c *cli.Context, cfg *config.RocketPoolConfig, rp *rocketpool.RocketPool, log log.ColorLogger) error {
+
+	if !c.IsSet("storage-address") || c.IsSet("reth-address") {
+		return nil
+	}
+	address, err := rp.GetAddress("rocketTokenRETH", nil)
+	if err != nil {
+		return fmt.Errorf("error getting the rETH address from RocketStorage: %w", err)
+	}
+	network := cfg.Smartnode.Network.Value.(cfgtypes.Network)
+	err = setSmartnodeValue(cfg, "rethAddress", network, address.Hex())
+	if err != nil {
+		return err
+	}
+	log.Printlnf("Using reth-address %s from RocketStorage.", address.Hex())
+	return nil
+
+}
+
+// Set a network's entry in one of the per-network maps of the Smartnode config. These maps are unexported and only
+// hold the built-in deployments, so this is the only way to point the Smartnode's own code (such as the network state
+// manager's multicall contracts) at a custom deployment.
+func setSmartnodeValue(cfg *config.RocketPoolConfig, field string, network cfgtypes.Network, value any) error {
+
+	fieldValue := reflect.ValueOf(cfg.Smartnode).Elem().FieldByName(field)
+	if !fieldValue.IsValid() || fieldValue.Kind() != reflect.Map {
+		return fmt.Errorf("the Smartnode config has no per-network map named %s", field)
+	}
+	if fieldValue.Type().Elem() != reflect.TypeOf(value) {
+		return fmt.Errorf("the Smartnode config's %s map holds %s values, not %T", field, fieldValue.Type().Elem(), value)
+	}
+
+	// Get a settable version of the unexported field
+	fieldValue = reflect.NewAt(fieldValue.Type(), unsafe.Pointer(fieldValue.UnsafeAddr())).Elem()
+	if fieldValue.IsNil() {
+		fieldValue.Set(reflect.MakeMap(fieldValue.Type()))
+	}
+	fieldValue.SetMapIndex(reflect.ValueOf(network), reflect.ValueOf(value))
+	return nil
+
+}
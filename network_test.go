@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/rocket-pool/smartnode/shared/services/config"
+	cfgtypes "github.com/rocket-pool/smartnode/shared/types/config"
+)
+
+// Every per-network map that odaotool overrides has to exist in the Smartnode config with the expected type, and be
+// what the Smartnode's getters read; this fails if a Smartnode update renames or changes one of them
+func TestSetSmartnodeValue(t *testing.T) {
+
+	address := "0x1234567890123456789012345678901234567890"
+	getters := map[string]func(cfg *config.RocketPoolConfig) any{
+		"storageAddress":        func(cfg *config.RocketPoolConfig) any { return cfg.Smartnode.GetStorageAddress() },
+		"rethAddress":           func(cfg *config.RocketPoolConfig) any { return cfg.Smartnode.GetRethAddress().Hex() },
+		"rplTwapPoolAddress":    func(cfg *config.RocketPoolConfig) any { return cfg.Smartnode.GetRplTwapPoolAddress() },
+		"multicallAddress":      func(cfg *config.RocketPoolConfig) any { return cfg.Smartnode.GetMulticallAddress() },
+		"balancebatcherAddress": func(cfg *config.RocketPoolConfig) any { return cfg.Smartnode.GetBalanceBatcherAddress() },
+	}
+
+	type overrideTest struct {
+		field    string
+		value    any
+		expected any
+		get      func(cfg *config.RocketPoolConfig) any
+	}
+	tests := []overrideTest{
+		{
+			field:    "chainID",
+			value:    uint(31337),
+			expected: uint(31337),
+			get:      func(cfg *config.RocketPoolConfig) any { return cfg.Smartnode.GetChainID() },
+		},
+		{
+			field:    "previousRewardsPoolAddresses",
+			value:    map[string][]common.Address{"v1.1.0-rc1": {common.HexToAddress(address)}},
+			expected: map[string][]common.Address{"v1.1.0-rc1": {common.HexToAddress(address)}},
+			get:      func(cfg *config.RocketPoolConfig) any { return cfg.Smartnode.GetPreviousRewardsPoolAddresses() },
+		},
+		{
+			field:    "rewardsSubmissionBlockMaps",
+			value:    []uint64{},
+			expected: []uint64{},
+			get:      func(cfg *config.RocketPoolConfig) any { return cfg.Smartnode.GetRewardsSubmissionBlockMaps() },
+		},
+	}
+	for _, override := range addressOverrides {
+		get, exists := getters[override.field]
+		if !exists {
+			t.Fatalf("no getter to check the %s override with", override.field)
+		}
+		tests = append(tests, overrideTest{
+			field:    override.field,
+			value:    address,
+			expected: address,
+			get:      get,
+		})
+	}
+
+	for _, test := range tests {
+		t.Run(test.field, func(t *testing.T) {
+			cfg := config.NewRocketPoolConfig("", true)
+			cfg.Smartnode.Network.Value = cfgtypes.Network_Devnet
+			err := setSmartnodeValue(cfg, test.field, cfgtypes.Network_Devnet, test.value)
+			if err != nil {
+				t.Fatalf("error setting %s: %s", test.field, err)
+			}
+			value := test.get(cfg)
+			if !reflect.DeepEqual(value, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, value)
+			}
+
+			// Other configs keep the built-in values
+			other := config.NewRocketPoolConfig("", true)
+			other.Smartnode.Network.Value = cfgtypes.Network_Devnet
+			if reflect.DeepEqual(test.get(other), test.expected) {
+				t.Errorf("setting %s changed a different config", test.field)
+			}
+		})
+	}
+
+}
+
+func TestSetSmartnodeValueErrors(t *testing.T) {
+	cfg := config.NewRocketPoolConfig("", true)
+	err := setSmartnodeValue(cfg, "noSuchAddress", cfgtypes.Network_Devnet, "0x")
+	if err == nil {
+		t.Error("expected an error for a field that doesn't exist")
+	}
+	err = setSmartnodeValue(cfg, "chainID", cfgtypes.Network_Devnet, "31337")
+	if err == nil {
+		t.Error("expected an error for a value of the wrong type")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Output formats
+const (
+	outputFormatText string = "text"
+	outputFormatJson string = "json"
+)
+
+// Get the output format requested by the user
+func getOutputFormat(c *cli.Context) (string, error) {
+	format := c.String("output")
+	switch format {
+	case outputFormatText, outputFormatJson:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown output format [%s], must be '%s' or '%s'", format, outputFormatText, outputFormatJson)
+	}
+}
+
+// Print an object to stdout as an indented JSON document
+func printJson(v interface{}) error {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing output: %w", err)
+	}
+	fmt.Println(string(bytes))
+	return nil
+}
+
+// Write a CSV file with a header row
+func writeCsvFile(path string, header []string, records [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", path, err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write(header)
+	writer.WriteAll(records)
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
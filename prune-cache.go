@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Temporary files older than this are left over from an interrupted run, rather than being written right now
+const stateSnapshotTempMaxAge time.Duration = time.Hour
+
+// Prune cache task
+type pruneCache struct {
+	c      *cli.Context
+	log    log.ColorLogger
+	errLog log.ColorLogger
+	cache  *stateCache
+	maxAge time.Duration
+	all    bool
+}
+
+// Create prune cache task
+func newPruneCache(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*pruneCache, error) {
+
+	cache, err := newStateCache(c, logger)
+	if err != nil {
+		return nil, err
+	}
+	if cache == nil {
+		return nil, fmt.Errorf("cache-dir must be provided")
+	}
+	maxAge := c.Duration("max-age")
+	if maxAge < 0 {
+		return nil, fmt.Errorf("max-age must be non-negative")
+	}
+
+	// Return task
+	return &pruneCache{
+		c:      c,
+		log:    logger,
+		errLog: errorLogger,
+		cache:  cache,
+		maxAge: maxAge,
+		all:    c.Bool("all"),
+	}, nil
+
+}
+
+// Remove snapshots that are unusable, too old, or all of them
+func (t *pruneCache) run() error {
+
+	names, err := t.cache.getFiles()
+	if err != nil {
+		return err
+	}
+
+	removed := 0
+	var freed int64
+	for _, name := range names {
+		path := filepath.Join(t.cache.dir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("error checking %s: %w", path, err)
+		}
+
+		// Get the reason to remove the file, if any
+		var reason string
+		if strings.HasSuffix(name, stateSnapshotTempExt) {
+			if time.Since(info.ModTime()) > stateSnapshotTempMaxAge {
+				reason = "leftover temporary file"
+			}
+		} else if t.all {
+			reason = "removing all snapshots"
+		} else {
+			header, err := readStateSnapshotHeader(path)
+			if err != nil {
+				reason = fmt.Sprintf("unreadable (%s)", err.Error())
+			} else if header.Version != stateSnapshotVersion {
+				reason = fmt.Sprintf("format version %d is outdated", header.Version)
+			} else if t.maxAge > 0 && time.Since(header.CreatedAt) > t.maxAge {
+				reason = fmt.Sprintf("created at %s, older than %s", header.CreatedAt.Format(time.RFC3339), t.maxAge)
+			}
+		}
+		if reason == "" {
+			continue
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return fmt.Errorf("error removing %s: %w", path, err)
+		}
+		t.log.Printlnf("Removed %s: %s", name, reason)
+		removed++
+		freed += info.Size()
+	}
+
+	t.log.Printlnf("Removed %d of %d files from %s, freeing %.1f MiB.", removed, len(names), t.cache.dir, float64(freed)/(1024*1024))
+	return nil
+
+}
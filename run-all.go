@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rocket-pool/rocketpool-go/network"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/rocket-pool/rocketpool-go/utils/eth"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	"github.com/rocket-pool/smartnode/shared/services/config"
+	"github.com/rocket-pool/smartnode/shared/services/state"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+	mathutils "github.com/rocket-pool/smartnode/shared/utils/math"
+)
+
+// Run all duties task
+type runAll struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	cfg          *config.RocketPoolConfig
+	ec           rocketpool.ExecutionClient
+	rp           *rocketpool.RocketPool
+	bc           beacon.Client
+	mgr          *stateManager
+	price        *submitRplPrice
+	balances     *submitNetworkBalances
+	scrub        *scrubMinipools
+	outputFormat string
+}
+
+// Machine-readable RPL price result
+type rplPriceOutput struct {
+	Block    uint64  `json:"block"`
+	Price    string  `json:"price"`
+	PriceEth float64 `json:"priceEth"`
+}
+
+// Machine-readable combined report of every duty
+type runAllOutput struct {
+	ElBlock         uint64                 `json:"elBlock"`
+	BeaconSlot      uint64                 `json:"beaconSlot"`
+	StateLoadTime   string                 `json:"stateLoadTime"`
+	RplPrice        *rplPriceOutput        `json:"rplPrice,omitempty"`
+	NetworkBalances *networkBalancesOutput `json:"networkBalances,omitempty"`
+	Scrubs          *scrubMinipoolsOutput  `json:"scrubMinipools,omitempty"`
+	TimedOut        []timedOutMinipool     `json:"timedOutMinipools"`
+	BondReductions  []pendingBondReduction `json:"bondReductions"`
+	SoloMigrations  []soloMigration        `json:"soloMigrations"`
+	Skipped         []string               `json:"skipped"`
+	Errors          map[string]string      `json:"errors"`
+}
+
+// The blocks the price and balance duties are simulated for
+type runAllDutyBlocks struct {
+	prices   uint64
+	balances uint64
+
+	// The header of the balances block, if it was already retrieved
+	balancesHeader *types.Header
+
+	// A client that has the state of the balances block available
+	client *rocketpool.RocketPool
+}
+
+// Create run all duties task
+func newRunAll(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*runAll, error) {
+
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ec, bc, rp, cfg, mgr, err := initialize(c, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing RP artifacts: %w", err)
+	}
+
+	price, balances := newDutyTasks(c, logger, errorLogger, ec, bc, rp, cfg, mgr)
+
+	// Return task
+	return &runAll{
+		c:        c,
+		log:      logger,
+		errLog:   errorLogger,
+		cfg:      cfg,
+		ec:       ec,
+		rp:       rp,
+		bc:       bc,
+		mgr:      mgr,
+		price:    price,
+		balances: balances,
+		scrub: &scrubMinipools{
+			c:      c,
+			log:    logger,
+			errLog: errorLogger,
+			cfg:    cfg,
+			ec:     ec,
+			rp:     rp,
+			bc:     bc,
+			mgr:    mgr,
+		},
+		outputFormat: outputFormat,
+	}, nil
+
+}
+
+// Run every duty against the target block
+func (t *runAll) run() error {
+
+	// Load the state once for every duty
+	start := time.Now()
+	state, blocks, err := t.getSharedState()
+	if err != nil {
+		return err
+	}
+	stateLoadTime := time.Since(start)
+	t.log.Printlnf("Loaded network state for EL block %d, CL slot %d in %s.", state.ElBlockNumber, state.BeaconSlotNumber, stateLoadTime)
+
+	output := t.runDuties(state, blocks)
+	output.StateLoadTime = stateLoadTime.String()
+
+	// Print the results
+	if output.RplPrice != nil {
+		t.log.Printlnf("[Prices] RPL price at block %d: %.6f ETH", output.RplPrice.Block, output.RplPrice.PriceEth)
+	}
+	if output.NetworkBalances != nil {
+		t.log.Printlnf("[Balances] Total ETH at block %d: %s wei, rETH supply: %s wei, ratio: %.6f", output.NetworkBalances.ElBlock, output.NetworkBalances.TotalEth, output.NetworkBalances.RETHSupply, output.NetworkBalances.RETHRatio)
+	}
+	if output.Scrubs != nil {
+		t.log.Printlnf("[Scrub] %d of %d prelaunch minipools would be scrubbed.", len(output.Scrubs.Scrubs), output.Scrubs.Counts.TotalMinipools)
+		for _, scrub := range output.Scrubs.Scrubs {
+			t.errLog.Printlnf("[Scrub] Minipool %s: %s", scrub.Address.Hex(), scrub.Reason)
+		}
+	}
+	t.log.Printlnf("[Dissolve] %d minipools have timed out and would be dissolved.", len(output.TimedOut))
+	for _, mp := range output.TimedOut {
+		t.errLog.Printlnf("[Dissolve] Minipool %s: overdue by %s", mp.Address.Hex(), mp.Overdue)
+	}
+	cancelCount := 0
+	for _, reduction := range output.BondReductions {
+		if reduction.Cancel {
+			cancelCount++
+			t.errLog.Printlnf("[Bond Reductions] Minipool %s: %s", reduction.Address.Hex(), reduction.Reason)
+		}
+	}
+	t.log.Printlnf("[Bond Reductions] %d of %d pending bond reductions would be cancelled.", cancelCount, len(output.BondReductions))
+	scrubCount := 0
+	for _, migration := range output.SoloMigrations {
+		if migration.Scrub {
+			scrubCount++
+			t.errLog.Printlnf("[Solo Migrations] Minipool %s: %s", migration.Address.Hex(), migration.Reason)
+		}
+	}
+	t.log.Printlnf("[Solo Migrations] %d of %d vacant minipools would be scrubbed.", scrubCount, len(output.SoloMigrations))
+	for _, skipped := range output.Skipped {
+		t.log.Printlnf("Skipped: %s", skipped)
+	}
+
+	// Print the machine-readable report
+	if t.outputFormat == outputFormatJson {
+		err = printJson(output)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(output.Errors) > 0 {
+		names := make([]string, 0, len(output.Errors))
+		for name, err := range output.Errors {
+			t.errLog.Printlnf("[%s] %s", name, err)
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("%d duties failed: %v", len(names), names)
+	}
+	return nil
+
+}
+
+// Get the state shared by every duty, along with the blocks the price and balance duties are for. Like the
+// watchtower, the balance duty is simulated against the state of its reportable block, so that's the state that gets
+// loaded and shared; the price duty only needs its block. If reportable block resolution is disabled, the state of the
+// target block is used for everything.
+func (t *runAll) getSharedState() (*state.NetworkState, runAllDutyBlocks, error) {
+
+	if !t.c.Bool("reportable-block") {
+		state, err := getTargetState(t.c, t.ec, t.mgr, t.log)
+		if err != nil {
+			return nil, runAllDutyBlocks{}, err
+		}
+		blocks := runAllDutyBlocks{
+			prices:   getDutyBlock(t.c, t.log, state.ElBlockNumber, state.NetworkDetails.LatestReportablePricesBlock),
+			balances: state.ElBlockNumber,
+			client:   t.rp,
+		}
+		return state, blocks, nil
+	}
+
+	// Get the target block without loading its state
+	var targetBlock *big.Int
+	if t.c.IsSet("target-block") {
+		targetBlock = big.NewInt(0).SetUint64(t.c.Uint64("target-block"))
+	} else {
+		header, err := t.ec.HeaderByNumber(context.Background(), nil)
+		if err != nil {
+			return nil, runAllDutyBlocks{}, fmt.Errorf("error getting the latest EL block: %w", err)
+		}
+		targetBlock = header.Number
+		t.log.Printlnf("Target block not set, using the chain head (%s).", targetBlock)
+	}
+
+	// Get the latest reportable blocks as of the target block
+	opts := &bind.CallOpts{
+		BlockNumber: targetBlock,
+	}
+	pricesBlock, err := network.GetLatestReportablePricesBlock(t.rp, opts)
+	if err != nil {
+		return nil, runAllDutyBlocks{}, fmt.Errorf("error getting latest reportable prices block: %w", err)
+	}
+	balancesBlock, err := network.GetLatestReportableBalancesBlock(t.rp, opts)
+	if err != nil {
+		return nil, runAllDutyBlocks{}, fmt.Errorf("error getting latest reportable balances block: %w", err)
+	}
+	t.log.Printlnf("Target block is %s, latest reportable prices block is %s, latest reportable balances block is %s.", targetBlock, pricesBlock, balancesBlock)
+
+	// Load the state of the balances block
+	header, err := t.ec.HeaderByNumber(context.Background(), balancesBlock)
+	if err != nil {
+		return nil, runAllDutyBlocks{}, fmt.Errorf("error getting header for EL block %s: %w", balancesBlock, err)
+	}
+	slotNumber := getBeaconSlotForBlock(header, t.mgr.BeaconConfig)
+	client, state, err := t.balances.getStateForBalances(balancesBlock, slotNumber)
+	if err != nil {
+		return nil, runAllDutyBlocks{}, err
+	}
+	blocks := runAllDutyBlocks{
+		prices:         pricesBlock.Uint64(),
+		balances:       balancesBlock.Uint64(),
+		balancesHeader: header,
+		client:         client,
+	}
+	return state, blocks, nil
+
+}
+
+// Run every duty concurrently against a network state. A failing duty is recorded in the report instead of
+// stopping the others.
+func (t *runAll) runDuties(state *state.NetworkState, blocks runAllDutyBlocks) runAllOutput {
+
+	output := runAllOutput{
+		ElBlock:    state.ElBlockNumber,
+		BeaconSlot: state.BeaconSlotNumber,
+		Skipped:    []string{},
+		Errors:     map[string]string{},
+	}
+	var lock sync.Mutex
+	var wg errgroup.Group
+	runDuty := func(name string, duty func() error) {
+		wg.Go(func() error {
+			err := duty()
+			if err != nil {
+				lock.Lock()
+				output.Errors[name] = err.Error()
+				lock.Unlock()
+			}
+			return nil
+		})
+	}
+	skip := func(reason string) {
+		lock.Lock()
+		output.Skipped = append(output.Skipped, reason)
+		lock.Unlock()
+	}
+
+	// RPL price
+	runDuty("Prices", func() error {
+		if !state.NetworkDetails.SubmitPricesEnabled {
+			skip("price submissions are disabled")
+			return nil
+		}
+		blockNumber := blocks.prices
+		rplPrice, err := t.price.getRplTwap(blockNumber)
+		if err != nil {
+			return err
+		}
+		output.RplPrice = &rplPriceOutput{
+			Block:    blockNumber,
+			Price:    rplPrice.String(),
+			PriceEth: mathutils.RoundDown(eth.WeiToEth(rplPrice), 6),
+		}
+		return nil
+	})
+
+	// Network balances, reusing the loaded state if it's for the duty block
+	runDuty("Balances", func() error {
+		if !state.NetworkDetails.SubmitBalancesEnabled {
+			skip("balance submissions are disabled")
+			return nil
+		}
+		blockNumber := blocks.balances
+		var balances networkBalances
+		var slotNumber uint64
+		if blockNumber == state.ElBlockNumber {
+			var err error
+			header := blocks.balancesHeader
+			if header == nil {
+				header, err = t.ec.HeaderByNumber(context.Background(), big.NewInt(0).SetUint64(blockNumber))
+				if err != nil {
+					return fmt.Errorf("error getting header for EL block %d: %w", blockNumber, err)
+				}
+			}
+			slotNumber = state.BeaconSlotNumber
+			slotTime := time.Unix(int64(header.Time), 0)
+			balances, err = t.balances.getNetworkBalancesFromState(blocks.client, state, header, slotNumber, slotTime, state.IsAtlasDeployed)
+			if err != nil {
+				return err
+			}
+		} else {
+			t.log.Printlnf("[Balances] Duty block %d differs from the target state's block, loading its state separately.", blockNumber)
+			var err error
+			balances, slotNumber, err = t.balances.getNetworkBalancesForBlock(blockNumber)
+			if err != nil {
+				return err
+			}
+		}
+		balancesOutput := t.balances.getNetworkBalancesOutput(balances, slotNumber)
+		output.NetworkBalances = &balancesOutput
+		return nil
+	})
+
+	// Minipool scrubs
+	runDuty("Scrub", func() error {
+		scrubs, counts, err := t.scrub.getScrubs(state)
+		if err != nil {
+			return err
+		}
+		output.Scrubs = &scrubMinipoolsOutput{
+			ElBlock:    state.ElBlockNumber,
+			BeaconSlot: state.BeaconSlotNumber,
+			Counts:     counts,
+			Scrubs:     scrubs,
+		}
+		return nil
+	})
+
+	// Timed out minipools
+	runDuty("Dissolve", func() error {
+		blockTime := getSlotTime(state.BeaconSlotNumber, state.BeaconConfig)
+		launchTimeout := time.Duration(state.NetworkDetails.MinipoolLaunchTimeout.Uint64()) * time.Second
+		output.TimedOut = getTimedOutMinipools(state, blockTime, launchTimeout)
+		return nil
+	})
+
+	// Bond reductions and solo migrations
+	output.BondReductions = []pendingBondReduction{}
+	output.SoloMigrations = []soloMigration{}
+	if !state.IsAtlasDeployed {
+		skip("bond reductions and solo migrations (Atlas isn't deployed)")
+	} else {
+		runDuty("Bond Reductions", func() error {
+			reductions, _, err := getPendingBondReductions(state)
+			if err != nil {
+				return err
+			}
+			output.BondReductions = reductions
+			return nil
+		})
+		runDuty("Solo Migrations", func() error {
+			output.SoloMigrations = getSoloMigrations(state)
+			return nil
+		})
+	}
+
+	// Duties that don't simulate a single block
+	skip("check-penalties (it checks the blocks in a range of slots; run it separately)")
+	skip("generate-rewards-tree (it covers a whole rewards interval; run it separately)")
+
+	wg.Wait()
+	sort.Strings(output.Skipped)
+	return output
+
+}
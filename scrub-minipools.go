@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prysmaticlabs/prysm/v3/beacon-chain/core/signing"
+	prdeposit "github.com/prysmaticlabs/prysm/v3/contracts/deposit"
+	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
+	"github.com/rocket-pool/rocketpool-go/minipool"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	rptypes "github.com/rocket-pool/rocketpool-go/types"
+	"github.com/rocket-pool/rocketpool-go/utils"
+	"github.com/rocket-pool/rocketpool-go/utils/eth"
+	rpstate "github.com/rocket-pool/rocketpool-go/utils/state"
+	"github.com/urfave/cli/v2"
+	eth2types "github.com/wealdtech/go-eth2-types/v2"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	"github.com/rocket-pool/smartnode/shared/services/config"
+	"github.com/rocket-pool/smartnode/shared/services/state"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Settings, matching the watchtower
+const (
+	scrubBlockStartOffset = 100000
+	scrubSafetyDivider    = 2
+	scrubMinSafetyTime    = time.Duration(0) * time.Hour
+)
+
+// Scrub minipools task
+type scrubMinipools struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	cfg          *config.RocketPoolConfig
+	ec           rocketpool.ExecutionClient
+	rp           *rocketpool.RocketPool
+	bc           beacon.Client
+	mgr          *stateManager
+	outputFormat string
+}
+
+// The check that caught a minipool that would be scrubbed
+type scrubReason string
+
+const (
+	scrubReasonBeaconCredentials  scrubReason = "beacon-withdrawal-credentials"
+	scrubReasonPrestakeSignature  scrubReason = "invalid-prestake-signature"
+	scrubReasonDepositCredentials scrubReason = "deposit-withdrawal-credentials"
+	scrubReasonSafety             scrubReason = "safety-scrub"
+)
+
+// A minipool that the Oracle DAO would vote to scrub
+type minipoolScrub struct {
+	Address             common.Address          `json:"address"`
+	NodeAddress         common.Address          `json:"nodeAddress"`
+	Pubkey              rptypes.ValidatorPubkey `json:"pubkey"`
+	Reason              scrubReason             `json:"reason"`
+	ExpectedCredentials string                  `json:"expectedWithdrawalCredentials"`
+	ActualCredentials   string                  `json:"actualWithdrawalCredentials,omitempty"`
+	DepositTxHash       string                  `json:"depositTxHash,omitempty"`
+	Detail              string                  `json:"detail,omitempty"`
+}
+
+// Tallies of each scrub check
+type scrubCounts struct {
+	TotalMinipools        int `json:"totalMinipools"`
+	VacantMinipools       int `json:"vacantMinipools"`
+	GoodOnBeacon          int `json:"goodOnBeacon"`
+	BadOnBeacon           int `json:"badOnBeacon"`
+	GoodPrestake          int `json:"goodPrestake"`
+	BadPrestake           int `json:"badPrestake"`
+	GoodOnDepositContract int `json:"goodOnDepositContract"`
+	BadOnDepositContract  int `json:"badOnDepositContract"`
+	UnknownMinipools      int `json:"unknownMinipools"`
+	SafetyScrubs          int `json:"safetyScrubs"`
+}
+
+// Machine-readable scrub check report
+type scrubMinipoolsOutput struct {
+	ElBlock    uint64          `json:"elBlock"`
+	BeaconSlot uint64          `json:"beaconSlot"`
+	Counts     scrubCounts     `json:"counts"`
+	Scrubs     []minipoolScrub `json:"scrubs"`
+}
+
+// A prelaunch minipool that is still being checked
+type scrubCandidate struct {
+	mp      minipool.Minipool
+	details *rpstate.NativeMinipoolDetails
+}
+
+// Create scrub minipools task
+func newScrubMinipools(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*scrubMinipools, error) {
+
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ec, bc, rp, cfg, mgr, err := initialize(c, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing RP artifacts: %w", err)
+	}
+
+	// Return task
+	return &scrubMinipools{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		cfg:          cfg,
+		ec:           ec,
+		rp:           rp,
+		bc:           bc,
+		mgr:          mgr,
+		outputFormat: outputFormat,
+	}, nil
+
+}
+
+// Check for minipools to scrub
+func (t *scrubMinipools) run() error {
+
+	state, err := getTargetState(t.c, t.ec, t.mgr, t.log)
+	if err != nil {
+		return err
+	}
+
+	scrubs, counts, err := t.getScrubs(state)
+	if err != nil {
+		return err
+	}
+
+	// Print the results
+	t.log.Printlnf("Checked %d prelaunch minipools at EL block %d, CL slot %d (%d vacant minipools skipped).", counts.TotalMinipools, state.ElBlockNumber, state.BeaconSlotNumber, counts.VacantMinipools)
+	t.log.Printlnf("\tBeacon Chain: %d good, %d bad", counts.GoodOnBeacon, counts.BadOnBeacon)
+	t.log.Printlnf("\tPrestake events: %d good, %d bad", counts.GoodPrestake, counts.BadPrestake)
+	t.log.Printlnf("\tDeposit contract: %d good, %d bad", counts.GoodOnDepositContract, counts.BadOnDepositContract)
+	t.log.Printlnf("\tNo deposit information: %d (%d safety scrubs)", counts.UnknownMinipools, counts.SafetyScrubs)
+	if len(scrubs) == 0 {
+		t.log.Println("No minipools would be scrubbed.")
+	}
+	for _, scrub := range scrubs {
+		t.errLog.Printlnf("Minipool %s (node %s) would be scrubbed: %s", scrub.Address.Hex(), scrub.NodeAddress.Hex(), scrub.Reason)
+		t.errLog.Printlnf("\tExpected creds: %s", scrub.ExpectedCredentials)
+		if scrub.ActualCredentials != "" {
+			t.errLog.Printlnf("\tActual creds: %s", scrub.ActualCredentials)
+		}
+		if scrub.DepositTxHash != "" {
+			t.errLog.Printlnf("\tDeposit TX: %s", scrub.DepositTxHash)
+		}
+		if scrub.Detail != "" {
+			t.errLog.Printlnf("\t%s", scrub.Detail)
+		}
+	}
+
+	// Print the machine-readable report
+	if t.outputFormat == outputFormatJson {
+		return printJson(scrubMinipoolsOutput{
+			ElBlock:    state.ElBlockNumber,
+			BeaconSlot: state.BeaconSlotNumber,
+			Counts:     counts,
+			Scrubs:     scrubs,
+		})
+	}
+	return nil
+
+}
+
+// Run the watchtower's scrub checks against every prelaunch minipool and get the ones that would be scrubbed.
+// This only reads from the chain; the scrub votes are never built or submitted.
+func (t *scrubMinipools) getScrubs(state *state.NetworkState) ([]minipoolScrub, scrubCounts, error) {
+
+	counts := scrubCounts{}
+	scrubs := []minipoolScrub{}
+	opts := &bind.CallOpts{
+		BlockNumber: big.NewInt(0).SetUint64(state.ElBlockNumber),
+	}
+
+	// Get minipools in prelaunch status, ignoring vacant ones since they have the wrong withdrawal creds by design
+	candidates := map[common.Address]*scrubCandidate{}
+	for i, mpd := range state.MinipoolDetails {
+		if mpd.Status != rptypes.Prelaunch {
+			continue
+		}
+		counts.TotalMinipools++
+		if mpd.IsVacant {
+			counts.VacantMinipools++
+			continue
+		}
+		mp, err := minipool.NewMinipoolFromVersion(t.rp, mpd.MinipoolAddress, mpd.Version, opts)
+		if err != nil {
+			return nil, counts, fmt.Errorf("error creating minipool wrapper for %s: %w", mpd.MinipoolAddress.Hex(), err)
+		}
+		candidates[mpd.MinipoolAddress] = &scrubCandidate{
+			mp:      mp,
+			details: &state.MinipoolDetails[i],
+		}
+	}
+	addScrub := func(candidate *scrubCandidate, reason scrubReason) *minipoolScrub {
+		scrubs = append(scrubs, minipoolScrub{
+			Address:             candidate.details.MinipoolAddress,
+			NodeAddress:         candidate.details.NodeAddress,
+			Pubkey:              candidate.details.Pubkey,
+			Reason:              reason,
+			ExpectedCredentials: candidate.details.WithdrawalCredentials.Hex(),
+		})
+		delete(candidates, candidate.details.MinipoolAddress)
+		return &scrubs[len(scrubs)-1]
+	}
+
+	// Step 1: verify the Beacon Chain credentials of the validators that have been seen there
+	for address, candidate := range candidates {
+		status := state.ValidatorDetails[candidate.details.Pubkey]
+		if !status.Exists {
+			continue
+		}
+		if status.WithdrawalCredentials != candidate.details.WithdrawalCredentials {
+			scrub := addScrub(candidate, scrubReasonBeaconCredentials)
+			scrub.ActualCredentials = status.WithdrawalCredentials.Hex()
+			counts.BadOnBeacon++
+		} else {
+			counts.GoodOnBeacon++
+			delete(candidates, address)
+		}
+	}
+	if len(candidates) == 0 {
+		sortScrubs(scrubs)
+		return scrubs, counts, nil
+	}
+
+	// Get the artifacts needed for the EL searches
+	eventLogInterval, err := t.cfg.GetEventLogInterval()
+	if err != nil {
+		return nil, counts, fmt.Errorf("error getting event log interval: %w", err)
+	}
+	intervalSize := big.NewInt(int64(eventLogInterval))
+	depositDomain, err := signing.ComputeDomain(eth2types.DomainDeposit, state.BeaconConfig.GenesisForkVersion, eth2types.ZeroGenesisValidatorsRoot)
+	if err != nil {
+		return nil, counts, fmt.Errorf("error computing deposit domain: %w", err)
+	}
+
+	// Step 2: verify the signature of each MinipoolPrestaked event
+	weiPerGwei := big.NewInt(int64(eth.WeiPerGwei))
+	for _, candidate := range candidates {
+		prestakeData, err := candidate.mp.GetPrestakeEvent(intervalSize, opts)
+		if err != nil {
+			return nil, counts, fmt.Errorf("error getting prestake event for minipool %s: %w", candidate.details.MinipoolAddress.Hex(), err)
+		}
+		depositData := new(ethpb.Deposit_Data)
+		depositData.Amount = big.NewInt(0).Div(prestakeData.Amount, weiPerGwei).Uint64()
+		depositData.PublicKey = prestakeData.Pubkey.Bytes()
+		depositData.WithdrawalCredentials = prestakeData.WithdrawalCredentials.Bytes()
+		depositData.Signature = prestakeData.Signature.Bytes()
+		err = prdeposit.VerifyDepositSignature(depositData, depositDomain)
+		if err != nil {
+			scrub := addScrub(candidate, scrubReasonPrestakeSignature)
+			scrub.Detail = err.Error()
+			counts.BadPrestake++
+		} else {
+			counts.GoodPrestake++
+		}
+	}
+	if len(candidates) == 0 {
+		sortScrubs(scrubs)
+		return scrubs, counts, nil
+	}
+
+	// Step 3: verify the first valid deposit of each remaining minipool on the deposit contract
+	startBlock := big.NewInt(0).SetUint64(state.ElBlockNumber)
+	offset := big.NewInt(scrubBlockStartOffset)
+	if startBlock.Cmp(offset) < 0 {
+		offset = startBlock
+	}
+	startBlock.Sub(startBlock, offset)
+	pubkeys := make(map[rptypes.ValidatorPubkey]bool, len(candidates))
+	for _, candidate := range candidates {
+		pubkeys[candidate.details.Pubkey] = true
+	}
+	depositMap, err := utils.GetDeposits(t.rp, pubkeys, startBlock, intervalSize, nil)
+	if err != nil {
+		return nil, counts, fmt.Errorf("error getting deposits: %w", err)
+	}
+	for address, candidate := range candidates {
+		for _, deposit := range depositMap[candidate.details.Pubkey] {
+			// Ignore deposits made after the target block
+			if deposit.BlockNumber > state.ElBlockNumber {
+				continue
+			}
+
+			depositData := new(ethpb.Deposit_Data)
+			depositData.Amount = deposit.Amount
+			depositData.PublicKey = deposit.Pubkey.Bytes()
+			depositData.WithdrawalCredentials = deposit.WithdrawalCredentials.Bytes()
+			depositData.Signature = deposit.Signature.Bytes()
+			err := prdeposit.VerifyDepositSignature(depositData, depositDomain)
+			if err != nil {
+				t.log.Printlnf("Ignoring invalid deposit for minipool %s in TX %s: %s", address.Hex(), deposit.TxHash.Hex(), err.Error())
+				continue
+			}
+
+			if deposit.WithdrawalCredentials != candidate.details.WithdrawalCredentials {
+				scrub := addScrub(candidate, scrubReasonDepositCredentials)
+				scrub.ActualCredentials = deposit.WithdrawalCredentials.Hex()
+				scrub.DepositTxHash = deposit.TxHash.Hex()
+				counts.BadOnDepositContract++
+			} else {
+				counts.GoodOnDepositContract++
+				delete(candidates, address)
+			}
+			break
+		}
+	}
+
+	// Step 4: scrub any minipools without a valid deposit after the safety period
+	counts.UnknownMinipools = len(candidates)
+	safetyPeriod := state.NetworkDetails.ScrubPeriod / scrubSafetyDivider
+	if safetyPeriod < scrubMinSafetyTime {
+		safetyPeriod = scrubMinSafetyTime
+	}
+	stateTime := getSlotTime(state.BeaconSlotNumber, state.BeaconConfig)
+	for _, candidate := range candidates {
+		statusTime := time.Unix(candidate.details.StatusTime.Int64(), 0)
+		timeInPrelaunch := stateTime.Sub(statusTime)
+		if timeInPrelaunch > safetyPeriod {
+			scrub := addScrub(candidate, scrubReasonSafety)
+			scrub.Detail = fmt.Sprintf("No valid deposit after %s in prelaunch (safety period is %s)", timeInPrelaunch, safetyPeriod)
+			counts.SafetyScrubs++
+		}
+	}
+
+	sortScrubs(scrubs)
+	return scrubs, counts, nil
+
+}
+
+// Sort scrubs by minipool address
+func sortScrubs(scrubs []minipoolScrub) {
+	sort.Slice(scrubs, func(i int, j int) bool {
+		return scrubs[i].Address.Hex() < scrubs[j].Address.Hex()
+	})
+}
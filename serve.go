@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/rocket-pool/rocketpool-go/network"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/rocket-pool/rocketpool-go/utils/eth"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+	mathutils "github.com/rocket-pool/smartnode/shared/utils/math"
+)
+
+// Serve task
+type serve struct {
+	c              *cli.Context
+	log            log.ColorLogger
+	errLog         log.ColorLogger
+	rp             *rocketpool.RocketPool
+	bc             beacon.Client
+	price          *submitRplPrice
+	balances       *submitNetworkBalances
+	listenAddress  string
+	cacheSize      int
+	stateLoadSlots chan struct{}
+
+	// Simulations in progress, keyed by duty and block so concurrent requests for the same block share one
+	inFlight singleflight.Group
+
+	// Finished simulations, keyed by block
+	cacheLock     sync.Mutex
+	priceCache    map[uint64]rplPriceOutput
+	balancesCache map[uint64]networkBalancesOutput
+}
+
+// The body of a failed request
+type serveError struct {
+	Error string `json:"error"`
+}
+
+// Create serve task
+func newServe(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*serve, error) {
+
+	maxStateLoads := c.Int("max-state-loads")
+	if maxStateLoads < 1 {
+		return nil, fmt.Errorf("max-state-loads must be at least 1")
+	}
+	cacheSize := c.Int("cache-size")
+	if cacheSize < 0 {
+		return nil, fmt.Errorf("cache-size must be non-negative")
+	}
+
+	ec, bc, rp, cfg, mgr, err := initialize(c, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing RP artifacts: %w", err)
+	}
+
+	price, balances := newDutyTasks(c, logger, errorLogger, ec, bc, rp, cfg, mgr)
+
+	// Return task
+	return &serve{
+		c:              c,
+		log:            logger,
+		errLog:         errorLogger,
+		rp:             rp,
+		bc:             bc,
+		price:          price,
+		balances:       balances,
+		listenAddress:  c.String("listen-address"),
+		cacheSize:      cacheSize,
+		stateLoadSlots: make(chan struct{}, maxStateLoads),
+		priceCache:     map[uint64]rplPriceOutput{},
+		balancesCache:  map[uint64]networkBalancesOutput{},
+	}, nil
+
+}
+
+// Serve duty simulations over HTTP until interrupted
+func (t *serve) run() error {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rpl-price", t.handleRplPrice)
+	mux.HandleFunc("/network-balances", t.handleNetworkBalances)
+
+	listener, err := net.Listen("tcp", t.listenAddress)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", t.listenAddress, err)
+	}
+	server := &http.Server{
+		Handler: mux,
+	}
+
+	// Stop cleanly on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		t.log.Println("Shutting down.")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := server.Shutdown(shutdownCtx)
+		if err != nil {
+			t.errLog.Printlnf("Error stopping server: %s", err.Error())
+		}
+	}()
+
+	t.log.Printlnf("Serving duty simulations on %s, press Ctrl+C to stop.", listener.Addr())
+	err = server.Serve(listener)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error running server: %w", err)
+	}
+	return nil
+
+}
+
+// Handle GET /rpl-price?block=N
+func (t *serve) handleRplPrice(w http.ResponseWriter, r *http.Request) {
+
+	blockNumber, status, err := t.getRequestBlock(r, network.GetLatestReportablePricesBlock)
+	if err != nil {
+		t.writeError(w, status, err)
+		return
+	}
+
+	t.cacheLock.Lock()
+	output, exists := t.priceCache[blockNumber]
+	t.cacheLock.Unlock()
+	if exists {
+		t.writeJson(w, output)
+		return
+	}
+
+	result, err, _ := t.inFlight.Do(fmt.Sprintf("prices-%d", blockNumber), func() (interface{}, error) {
+		rplPrice, err := t.price.getRplTwap(blockNumber)
+		if err != nil {
+			return nil, err
+		}
+		output := rplPriceOutput{
+			Block:    blockNumber,
+			Price:    rplPrice.String(),
+			PriceEth: mathutils.RoundDown(eth.WeiToEth(rplPrice), 6),
+		}
+		t.cacheLock.Lock()
+		t.priceCache[blockNumber] = output
+		pruneServeCache(t.priceCache, t.cacheSize)
+		t.cacheLock.Unlock()
+		return output, nil
+	})
+	if err != nil {
+		t.errLog.Printlnf("Error getting RPL price for block %d: %s", blockNumber, err.Error())
+		t.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	t.writeJson(w, result)
+
+}
+
+// Handle GET /network-balances?block=N
+func (t *serve) handleNetworkBalances(w http.ResponseWriter, r *http.Request) {
+
+	blockNumber, status, err := t.getRequestBlock(r, network.GetLatestReportableBalancesBlock)
+	if err != nil {
+		t.writeError(w, status, err)
+		return
+	}
+
+	t.cacheLock.Lock()
+	output, exists := t.balancesCache[blockNumber]
+	t.cacheLock.Unlock()
+	if exists {
+		t.writeJson(w, output)
+		return
+	}
+
+	result, err, _ := t.inFlight.Do(fmt.Sprintf("balances-%d", blockNumber), func() (interface{}, error) {
+		// Wait for a free state load slot
+		t.stateLoadSlots <- struct{}{}
+		defer func() {
+			<-t.stateLoadSlots
+		}()
+
+		balances, slotNumber, err := t.balances.getNetworkBalancesForBlock(blockNumber)
+		if err != nil {
+			return nil, err
+		}
+		output := t.balances.getNetworkBalancesOutput(balances, slotNumber)
+		t.cacheLock.Lock()
+		t.balancesCache[blockNumber] = output
+		pruneServeCache(t.balancesCache, t.cacheSize)
+		t.cacheLock.Unlock()
+		return output, nil
+	})
+	if err != nil {
+		t.errLog.Printlnf("Error calculating network balances for block %d: %s", blockNumber, err.Error())
+		t.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	t.writeJson(w, result)
+
+}
+
+// Get the block requested with the block query parameter, defaulting to the latest reportable block as of the latest
+// finalized block
+func (t *serve) getRequestBlock(r *http.Request, getReportableBlock func(*rocketpool.RocketPool, *bind.CallOpts) (*big.Int, error)) (uint64, int, error) {
+
+	if r.Method != http.MethodGet {
+		return 0, http.StatusMethodNotAllowed, fmt.Errorf("unsupported method %s", r.Method)
+	}
+
+	blockParam := r.URL.Query().Get("block")
+	if blockParam != "" {
+		blockNumber, err := strconv.ParseUint(blockParam, 10, 64)
+		if err != nil {
+			return 0, http.StatusBadRequest, fmt.Errorf("invalid block [%s]: %w", blockParam, err)
+		}
+		return blockNumber, http.StatusOK, nil
+	}
+
+	finalized, exists, err := t.bc.GetBeaconBlock("finalized")
+	if err != nil {
+		return 0, http.StatusInternalServerError, fmt.Errorf("error getting finalized beacon block: %w", err)
+	}
+	if !exists || !finalized.HasExecutionPayload {
+		return 0, http.StatusInternalServerError, fmt.Errorf("finalized beacon block %d doesn't have an execution payload", finalized.Slot)
+	}
+	reportableBlock, err := getReportableBlock(t.rp, &bind.CallOpts{
+		BlockNumber: big.NewInt(0).SetUint64(finalized.ExecutionBlockNumber),
+	})
+	if err != nil {
+		return 0, http.StatusInternalServerError, fmt.Errorf("error getting latest reportable block: %w", err)
+	}
+	return reportableBlock.Uint64(), http.StatusOK, nil
+
+}
+
+// Write a JSON response
+func (t *serve) writeJson(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		t.errLog.Printlnf("Error writing response: %s", err.Error())
+	}
+}
+
+// Write a JSON error response
+func (t *serve) writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encodeErr := json.NewEncoder(w).Encode(serveError{Error: err.Error()})
+	if encodeErr != nil {
+		t.errLog.Printlnf("Error writing response: %s", encodeErr.Error())
+	}
+}
+
+// Drop the lowest blocks from a cache until it holds at most size entries
+func pruneServeCache[T any](cache map[uint64]T, size int) {
+	if len(cache) <= size {
+		return
+	}
+	blocks := make([]uint64, 0, len(cache))
+	for block := range cache {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i] < blocks[j]
+	})
+	for _, block := range blocks[:len(cache)-size] {
+		delete(cache, block)
+	}
+}
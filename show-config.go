@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// What credentials are replaced with
+const credentialMask string = "xxxxx"
+
+var (
+	// A URL in an option's value
+	urlPattern = regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://[^\s,\]]+`)
+
+	// Query parameters that hold credentials
+	credentialParamPattern = regexp.MustCompile(`(?i)key|token|secret|pass|auth`)
+
+	// Path segments that look like API keys, as used by Infura, Alchemy and other providers
+	apiKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{20,}$`)
+)
+
+// Show config task
+type showConfig struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	sources      *configSources
+	outputFormat string
+}
+
+// An option's effective value and where it came from
+type configSetting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	EnvVar string `json:"envVar"`
+}
+
+// Machine-readable effective configuration
+type showConfigOutput struct {
+	ConfigFile string `json:"configFile"`
+
+	// Every global option
+	Global []configSetting `json:"global"`
+
+	// The command options that are set by an environment variable or the config file, keyed by command path
+	Commands map[string][]configSetting `json:"commands"`
+}
+
+// Create show config task
+func newShowConfig(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*showConfig, error) {
+
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+	sources := getConfigSources(c)
+	if sources == nil {
+		return nil, fmt.Errorf("config sources weren't initialized")
+	}
+
+	// Return task
+	return &showConfig{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		sources:      sources,
+		outputFormat: outputFormat,
+	}, nil
+
+}
+
+// Print the effective value of every global option, and the command options set outside the command line
+func (t *showConfig) run() error {
+
+	output := showConfigOutput{
+		ConfigFile: t.sources.path,
+		Global:     []configSetting{},
+		Commands:   map[string][]configSetting{},
+	}
+
+	// Global options have been parsed, so their effective values are known
+	for _, flag := range t.c.App.Flags {
+		if !isConfigurable(flag) {
+			continue
+		}
+		name := flag.Names()[0]
+		source, exists := t.sources.sources[name]
+		if !exists {
+			source = configSourceDefault
+		}
+		output.Global = append(output.Global, configSetting{
+			Name:   name,
+			Value:  maskCredentials(fmt.Sprint(t.c.Value(name))),
+			Source: source,
+			EnvVar: getEnvVarName(nil, name),
+		})
+	}
+
+	// Command options are only parsed when the command runs, so show the ones that the other sources will set
+	t.addCommandSettings(&output, t.c.App.Commands, nil)
+
+	if t.outputFormat == outputFormatJson {
+		return printJson(output)
+	}
+	if output.ConfigFile == "" {
+		t.log.Println("No config file loaded.")
+	} else {
+		t.log.Printlnf("Config file: %s", output.ConfigFile)
+	}
+	t.log.Println()
+	t.log.Println("Global options:")
+	t.printSettings(output.Global)
+	paths := []string{}
+	for path := range output.Commands {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		t.log.Println()
+		t.log.Printlnf("%s options:", path)
+		t.printSettings(output.Commands[path])
+	}
+	return nil
+
+}
+
+// Add the options of each command, and its subcommands, that are set by an environment variable or the config file
+func (t *showConfig) addCommandSettings(output *showConfigOutput, commands []*cli.Command, parentPath []string) {
+	for _, command := range commands {
+		path := append(append([]string{}, parentPath...), command.Name)
+		settings := []configSetting{}
+		for _, flag := range command.Flags {
+			if !isConfigurable(flag) {
+				continue
+			}
+			name := flag.Names()[0]
+			value, source, exists := t.sources.lookup(path, name)
+			if !exists {
+				continue
+			}
+			settings = append(settings, configSetting{
+				Name:   name,
+				Value:  maskCredentials(formatConfigValue(value)),
+				Source: source,
+				EnvVar: getEnvVarName(path, name),
+			})
+		}
+		if len(settings) > 0 {
+			output.Commands[getConfigKey(parentPath, command.Name)] = settings
+		}
+		t.addCommandSettings(output, command.Subcommands, path)
+	}
+}
+
+// Hide the credentials in any URLs in a value, since endpoint URLs often carry a password or an API key
+func maskCredentials(value string) string {
+	return urlPattern.ReplaceAllStringFunc(value, func(match string) string {
+		parsedUrl, err := url.Parse(match)
+		if err != nil {
+			return match
+		}
+		if parsedUrl.User != nil {
+			parsedUrl.User = url.User(credentialMask)
+		}
+		query := parsedUrl.Query()
+		for name := range query {
+			if credentialParamPattern.MatchString(name) {
+				query.Set(name, credentialMask)
+			}
+		}
+		parsedUrl.RawQuery = query.Encode()
+		segments := strings.Split(parsedUrl.Path, "/")
+		for i, segment := range segments {
+			if apiKeyPattern.MatchString(segment) {
+				segments[i] = credentialMask
+			}
+		}
+		parsedUrl.Path = strings.Join(segments, "/")
+		parsedUrl.RawPath = ""
+		return parsedUrl.String()
+	})
+}
+
+// Print a list of settings
+func (t *showConfig) printSettings(settings []configSetting) {
+	for _, setting := range settings {
+		t.log.Printlnf("  %s = %s (%s, %s)", setting.Name, setting.Value, setting.Source, setting.EnvVar)
+	}
+}
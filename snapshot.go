@@ -0,0 +1,172 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	"github.com/rocket-pool/smartnode/shared/services/config"
+	"github.com/rocket-pool/smartnode/shared/services/state"
+	cfgtypes "github.com/rocket-pool/smartnode/shared/types/config"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// The version of the duty snapshot format
+const dutySnapshotVersion uint64 = 1
+
+// Everything the price and balance duties need for a target block, so they can be replayed without an EC or BN
+type dutySnapshot struct {
+	Version         uint64
+	Network         cfgtypes.Network
+	ChainID         uint
+	TargetBlock     uint64
+	ReportableBlock bool
+	CapturedAt      time.Time
+	BeaconConfig    beacon.Eth2Config
+	TargetState     stateSnapshotBody
+	Prices          *priceSnapshot
+	Balances        *balancesSnapshot
+}
+
+// The TWAP pool observation for the price duty's block
+type priceSnapshot struct {
+	Block           uint64
+	PoolAddress     common.Address
+	Interval        uint32
+	TickCumulatives []*big.Int
+}
+
+// The inputs of the balances duty for its block
+type balancesSnapshot struct {
+	Block           uint64
+	HeaderRlp       []byte
+	Slot            uint64
+	IsAtlasDeployed bool
+
+	// The network state at Slot, or nil if it's the same as the target state
+	State *stateSnapshotBody
+
+	// Approximating the Smoothing Pool share needs the clients, so it can't be recalculated offline
+	SmoothingPoolShare *big.Int
+}
+
+// Load the snapshot for the --from-snapshot option, along with a config for its network
+func loadDutySnapshot(c *cli.Context, logger log.ColorLogger) (*dutySnapshot, *config.RocketPoolConfig, error) {
+
+	path := c.String("from-snapshot")
+	snapshot, err := readDutySnapshot(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Printlnf("Replaying snapshot %s of %s block %d (captured at %s).", path, snapshot.Network, snapshot.TargetBlock, snapshot.CapturedAt.Format(time.RFC3339))
+
+	cfg := config.NewRocketPoolConfig("", true)
+	cfg.Smartnode.Network.Value = snapshot.Network
+	return snapshot, cfg, nil
+
+}
+
+// Read a snapshot file
+func readDutySnapshot(path string) (*dutySnapshot, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening snapshot: %w", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing snapshot %s: %w", path, err)
+	}
+
+	snapshot := &dutySnapshot{}
+	err = gob.NewDecoder(reader).Decode(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding snapshot %s: %w", path, err)
+	}
+	if snapshot.Version != dutySnapshotVersion {
+		return nil, fmt.Errorf("snapshot %s has format version %d, but this version of odaotool reads version %d", path, snapshot.Version, dutySnapshotVersion)
+	}
+	return snapshot, nil
+
+}
+
+// Write a snapshot file
+func writeDutySnapshot(path string, snapshot *dutySnapshot) error {
+
+	// Write to a temporary file first so a partially written snapshot is never left behind
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+"-*"+stateSnapshotTempExt)
+	if err != nil {
+		return fmt.Errorf("error creating snapshot file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	writer := gzip.NewWriter(file)
+	err = gob.NewEncoder(writer).Encode(snapshot)
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot: %w", err)
+	}
+	err = writer.Close()
+	if err != nil {
+		return fmt.Errorf("error compressing snapshot: %w", err)
+	}
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("error writing %s: %w", file.Name(), err)
+	}
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		return fmt.Errorf("error moving snapshot to %s: %w", path, err)
+	}
+	return nil
+
+}
+
+// Get the network state of the snapshot's target block
+func (s *dutySnapshot) getTargetState(c *cli.Context, log log.ColorLogger) (*state.NetworkState, error) {
+	if c.IsSet("target-block") && c.Uint64("target-block") != s.TargetBlock {
+		return nil, fmt.Errorf("target block %d was requested, but the snapshot is for block %d", c.Uint64("target-block"), s.TargetBlock)
+	}
+	if c.Bool("reportable-block") != s.ReportableBlock {
+		log.Printlnf("NOTE: the snapshot was captured with --reportable-block=%t, so its duties may be for different blocks.", s.ReportableBlock)
+	}
+	return s.TargetState.getNetworkState(), nil
+}
+
+// Get the RPL price from the snapshot's TWAP pool observation
+func (s *dutySnapshot) getRplPrice(blockNumber uint64) (*big.Int, error) {
+	if s.Prices == nil {
+		return nil, fmt.Errorf("snapshot doesn't include the RPL price (price submissions were disabled when it was captured)")
+	}
+	if s.Prices.Block != blockNumber {
+		return nil, fmt.Errorf("snapshot has the RPL price for block %d, not %d", s.Prices.Block, blockNumber)
+	}
+	if len(s.Prices.TickCumulatives) != 2 || s.Prices.TickCumulatives[0] == nil || s.Prices.TickCumulatives[1] == nil {
+		return nil, fmt.Errorf("snapshot's TWAP pool observation has %d tick cumulatives instead of 2", len(s.Prices.TickCumulatives))
+	}
+	response := poolObserveResponse{
+		TickCumulatives: s.Prices.TickCumulatives,
+	}
+	return getRplPriceFromObservation(response, s.Prices.Interval), nil
+}
+
+// Get the EL block header for the balances duty
+func (s *balancesSnapshot) getHeader() (*types.Header, error) {
+	header := &types.Header{}
+	err := rlp.DecodeBytes(s.HeaderRlp, header)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding header of EL block %d: %w", s.Block, err)
+	}
+	return header, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	rpstate "github.com/rocket-pool/rocketpool-go/utils/state"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	cfgtypes "github.com/rocket-pool/smartnode/shared/types/config"
+)
+
+// Get the tick cumulatives of a pool whose average tick over the interval was tick
+func getTickCumulatives(tick int64, interval uint32) []*big.Int {
+	start := big.NewInt(123456789)
+	end := big.NewInt(0).Add(start, big.NewInt(tick*int64(interval)))
+	return []*big.Int{start, end}
+}
+
+func TestGetRplPriceFromObservation(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		tick  int64
+		price string
+	}{
+		{name: "tick 0", tick: 0, price: "1000000000000000000"},
+		{name: "tick 1", tick: 1, price: "999900009999000099"},
+		{name: "tick 2", tick: 2, price: "999800029996000499"},
+		{name: "about 0.01 ETH", tick: 46054, price: "10000004406380063"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := poolObserveResponse{
+				TickCumulatives: getTickCumulatives(test.tick, twapNumberOfSeconds),
+			}
+			price := getRplPriceFromObservation(response, twapNumberOfSeconds)
+			if price.String() != test.price {
+				t.Errorf("expected price %s, got %s", test.price, price)
+			}
+		})
+	}
+
+}
+
+func TestDutySnapshotRoundTrip(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "snapshot.gob.gz")
+	snapshot := &dutySnapshot{
+		Version:         dutySnapshotVersion,
+		Network:         cfgtypes.Network_Mainnet,
+		ChainID:         1,
+		TargetBlock:     16900000,
+		ReportableBlock: true,
+		CapturedAt:      time.Date(2023, 3, 24, 12, 0, 0, 0, time.UTC),
+		BeaconConfig: beacon.Eth2Config{
+			GenesisTime:    1606824023,
+			SecondsPerSlot: 12,
+			SlotsPerEpoch:  32,
+		},
+		TargetState: stateSnapshotBody{
+			IsAtlasDeployed:  true,
+			ElBlockNumber:    16900000,
+			BeaconSlotNumber: 6100000,
+			NetworkDetails: &rpstate.NetworkDetails{
+				SubmitPricesEnabled:   true,
+				SubmitBalancesEnabled: true,
+			},
+		},
+		Prices: &priceSnapshot{
+			Block:           16899000,
+			PoolAddress:     common.HexToAddress("0xe42318ea3b998e8355a3da364eb9d48ec725eb45"),
+			Interval:        twapNumberOfSeconds,
+			TickCumulatives: getTickCumulatives(46054, twapNumberOfSeconds),
+		},
+		Balances: &balancesSnapshot{
+			Block:              16899500,
+			Slot:               6099500,
+			IsAtlasDeployed:    true,
+			SmoothingPoolShare: big.NewInt(1234567890),
+		},
+	}
+
+	err := writeDutySnapshot(path, snapshot)
+	if err != nil {
+		t.Fatalf("error writing snapshot: %s", err)
+	}
+	loaded, err := readDutySnapshot(path)
+	if err != nil {
+		t.Fatalf("error reading snapshot: %s", err)
+	}
+
+	if loaded.Network != snapshot.Network || loaded.ChainID != snapshot.ChainID || loaded.TargetBlock != snapshot.TargetBlock {
+		t.Errorf("expected %s chain %d block %d, got %s chain %d block %d", snapshot.Network, snapshot.ChainID, snapshot.TargetBlock, loaded.Network, loaded.ChainID, loaded.TargetBlock)
+	}
+	if !loaded.CapturedAt.Equal(snapshot.CapturedAt) {
+		t.Errorf("expected capture time %s, got %s", snapshot.CapturedAt, loaded.CapturedAt)
+	}
+	if !reflect.DeepEqual(loaded.BeaconConfig, snapshot.BeaconConfig) {
+		t.Errorf("expected Beacon config %+v, got %+v", snapshot.BeaconConfig, loaded.BeaconConfig)
+	}
+	state := loaded.TargetState.getNetworkState()
+	if state.ElBlockNumber != 16900000 || state.BeaconSlotNumber != 6100000 || !state.NetworkDetails.SubmitBalancesEnabled {
+		t.Errorf("target state wasn't restored: block %d, slot %d", state.ElBlockNumber, state.BeaconSlotNumber)
+	}
+	if loaded.Balances.SmoothingPoolShare.Cmp(snapshot.Balances.SmoothingPoolShare) != 0 {
+		t.Errorf("expected Smoothing Pool share %s, got %s", snapshot.Balances.SmoothingPoolShare, loaded.Balances.SmoothingPoolShare)
+	}
+
+	price, err := loaded.getRplPrice(snapshot.Prices.Block)
+	if err != nil {
+		t.Fatalf("error getting RPL price: %s", err)
+	}
+	if price.String() != "10000004406380063" {
+		t.Errorf("expected price 10000004406380063, got %s", price)
+	}
+	_, err = loaded.getRplPrice(snapshot.Prices.Block + 1)
+	if err == nil {
+		t.Error("expected an error for a block the snapshot doesn't have the price of")
+	}
+
+}
+
+func TestDutySnapshotVersionMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.gob.gz")
+	err := writeDutySnapshot(path, &dutySnapshot{Version: dutySnapshotVersion + 1})
+	if err != nil {
+		t.Fatalf("error writing snapshot: %s", err)
+	}
+	_, err = readDutySnapshot(path)
+	if err == nil || !strings.Contains(err.Error(), "format version") {
+		t.Errorf("expected a format version error, got %v", err)
+	}
+}
+
+func TestDutySnapshotTruncatedObservation(t *testing.T) {
+	snapshot := &dutySnapshot{
+		Prices: &priceSnapshot{
+			Block:           16899000,
+			Interval:        twapNumberOfSeconds,
+			TickCumulatives: []*big.Int{big.NewInt(123456789)},
+		},
+	}
+	_, err := snapshot.getRplPrice(16899000)
+	if err == nil {
+		t.Error("expected an error for an observation with one tick cumulative")
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	rptypes "github.com/rocket-pool/rocketpool-go/types"
+	rpstate "github.com/rocket-pool/rocketpool-go/utils/state"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	"github.com/rocket-pool/smartnode/shared/services/state"
+	cfgtypes "github.com/rocket-pool/smartnode/shared/types/config"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// The version of the snapshot format; snapshots from other versions are ignored and reloaded
+const stateSnapshotVersion uint64 = 1
+
+const (
+	stateSnapshotExtension string = ".gob.gz"
+	stateSnapshotTempExt   string = ".tmp"
+)
+
+// A directory of serialized network states, keyed by network and slot
+type stateCache struct {
+	dir string
+	log log.ColorLogger
+}
+
+// Identifies the state in a snapshot; it's written before the state so it can be checked without decoding the state
+type stateSnapshotHeader struct {
+	Version     uint64
+	Network     cfgtypes.Network
+	ChainID     uint
+	Slot        uint64
+	ElBlock     uint64
+	ElBlockHash common.Hash
+	CreatedAt   time.Time
+}
+
+// The parts of a network state that get serialized; the lookup maps are rebuilt when it's loaded
+type stateSnapshotBody struct {
+	IsAtlasDeployed  bool
+	ElBlockNumber    uint64
+	BeaconSlotNumber uint64
+	BeaconConfig     beacon.Eth2Config
+	NetworkDetails   *rpstate.NetworkDetails
+	NodeDetails      []rpstate.NativeNodeDetails
+	MinipoolDetails  []rpstate.NativeMinipoolDetails
+	ValidatorDetails map[rptypes.ValidatorPubkey]beacon.ValidatorStatus
+}
+
+// A network state manager that reads and writes states in the cache directory, if one is set
+type stateManager struct {
+	*state.NetworkStateManager
+	ec    rocketpool.ExecutionClient
+	cache *stateCache
+}
+
+// Create the state cache for the --cache-dir option, or nil if it isn't set
+func newStateCache(c *cli.Context, logger log.ColorLogger) (*stateCache, error) {
+	dir := c.String("cache-dir")
+	if dir == "" {
+		return nil, nil
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cache directory %s: %w", dir, err)
+	}
+	return &stateCache{
+		dir: dir,
+		log: logger,
+	}, nil
+}
+
+// Get the state of the network at the provided Beacon slot, from the cache if possible
+func (m *stateManager) GetStateForSlot(slotNumber uint64) (*state.NetworkState, error) {
+
+	if m.cache == nil {
+		return m.NetworkStateManager.GetStateForSlot(slotNumber)
+	}
+
+	// Try the cache first
+	networkState, err := m.cache.load(m.Network, m.ChainID, slotNumber, m.ec)
+	if err == nil {
+		m.cache.log.Printlnf("Loaded network state for slot %d from the cache.", slotNumber)
+		return networkState, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		m.cache.log.Printlnf("Ignoring cached network state for slot %d: %s", slotNumber, err.Error())
+	}
+
+	// Load it from the clients, and cache it for next time
+	networkState, err = m.NetworkStateManager.GetStateForSlot(slotNumber)
+	if err != nil {
+		return nil, err
+	}
+	err = m.cache.save(m.Network, m.ChainID, networkState, m.ec)
+	if err != nil {
+		m.cache.log.Printlnf("WARNING: couldn't cache network state for slot %d: %s", slotNumber, err.Error())
+	}
+	return networkState, nil
+
+}
+
+// Get the path of the snapshot for a slot
+func (c *stateCache) getPath(network cfgtypes.Network, slotNumber uint64) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%s-slot-%d%s", network, slotNumber, stateSnapshotExtension))
+}
+
+// Load a network state from the cache, checking that it's for the expected network, slot and EL block hash
+func (c *stateCache) load(network cfgtypes.Network, chainID uint, slotNumber uint64, ec rocketpool.ExecutionClient) (*state.NetworkState, error) {
+
+	path := c.getPath(network, slotNumber)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing %s: %w", path, err)
+	}
+	decoder := gob.NewDecoder(reader)
+
+	// Check the header
+	var header stateSnapshotHeader
+	err = decoder.Decode(&header)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding header of %s: %w", path, err)
+	}
+	if header.Version != stateSnapshotVersion {
+		return nil, fmt.Errorf("snapshot format version is %d, expected %d", header.Version, stateSnapshotVersion)
+	}
+	if header.Network != network || header.ChainID != chainID {
+		return nil, fmt.Errorf("snapshot is for network %s (chain ID %d), expected %s (chain ID %d)", header.Network, header.ChainID, network, chainID)
+	}
+	if header.Slot != slotNumber {
+		return nil, fmt.Errorf("snapshot is for slot %d, expected %d", header.Slot, slotNumber)
+	}
+	elBlockHeader, err := ec.HeaderByNumber(context.Background(), big.NewInt(0).SetUint64(header.ElBlock))
+	if err != nil {
+		return nil, fmt.Errorf("error getting header for EL block %d: %w", header.ElBlock, err)
+	}
+	if elBlockHeader.Hash() != header.ElBlockHash {
+		return nil, fmt.Errorf("EL block %d has hash %s, but the snapshot was taken at hash %s", header.ElBlock, elBlockHeader.Hash().Hex(), header.ElBlockHash.Hex())
+	}
+
+	// Decode the state
+	var body stateSnapshotBody
+	err = decoder.Decode(&body)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	if body.BeaconSlotNumber != header.Slot || body.ElBlockNumber != header.ElBlock {
+		return nil, fmt.Errorf("snapshot contents are for EL block %d, slot %d, but its header is for EL block %d, slot %d", body.ElBlockNumber, body.BeaconSlotNumber, header.ElBlock, header.Slot)
+	}
+	return body.getNetworkState(), nil
+
+}
+
+// Save a network state to the cache
+func (c *stateCache) save(network cfgtypes.Network, chainID uint, networkState *state.NetworkState, ec rocketpool.ExecutionClient) error {
+
+	elBlockHeader, err := ec.HeaderByNumber(context.Background(), big.NewInt(0).SetUint64(networkState.ElBlockNumber))
+	if err != nil {
+		return fmt.Errorf("error getting header for EL block %d: %w", networkState.ElBlockNumber, err)
+	}
+	header := stateSnapshotHeader{
+		Version:     stateSnapshotVersion,
+		Network:     network,
+		ChainID:     chainID,
+		Slot:        networkState.BeaconSlotNumber,
+		ElBlock:     networkState.ElBlockNumber,
+		ElBlockHash: elBlockHeader.Hash(),
+		CreatedAt:   time.Now().UTC(),
+	}
+	body := newStateSnapshotBody(networkState)
+
+	// Write to a temporary file first so a partially written snapshot is never picked up
+	path := c.getPath(network, networkState.BeaconSlotNumber)
+	file, err := os.CreateTemp(c.dir, filepath.Base(path)+"-*"+stateSnapshotTempExt)
+	if err != nil {
+		return fmt.Errorf("error creating snapshot file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	writer := gzip.NewWriter(file)
+	encoder := gob.NewEncoder(writer)
+	err = encoder.Encode(header)
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot header: %w", err)
+	}
+	err = encoder.Encode(body)
+	if err != nil {
+		return fmt.Errorf("error encoding network state: %w", err)
+	}
+	err = writer.Close()
+	if err != nil {
+		return fmt.Errorf("error compressing network state: %w", err)
+	}
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("error writing %s: %w", file.Name(), err)
+	}
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		return fmt.Errorf("error moving snapshot to %s: %w", path, err)
+	}
+	return nil
+
+}
+
+// Get the serializable parts of a network state
+func newStateSnapshotBody(networkState *state.NetworkState) stateSnapshotBody {
+	return stateSnapshotBody{
+		IsAtlasDeployed:  networkState.IsAtlasDeployed,
+		ElBlockNumber:    networkState.ElBlockNumber,
+		BeaconSlotNumber: networkState.BeaconSlotNumber,
+		BeaconConfig:     networkState.BeaconConfig,
+		NetworkDetails:   networkState.NetworkDetails,
+		NodeDetails:      networkState.NodeDetails,
+		MinipoolDetails:  networkState.MinipoolDetails,
+		ValidatorDetails: networkState.ValidatorDetails,
+	}
+}
+
+// Rebuild a network state, including its lookup maps, from a snapshot
+func (b *stateSnapshotBody) getNetworkState() *state.NetworkState {
+
+	networkState := &state.NetworkState{
+		IsAtlasDeployed:          b.IsAtlasDeployed,
+		ElBlockNumber:            b.ElBlockNumber,
+		BeaconSlotNumber:         b.BeaconSlotNumber,
+		BeaconConfig:             b.BeaconConfig,
+		NetworkDetails:           b.NetworkDetails,
+		NodeDetails:              b.NodeDetails,
+		NodeDetailsByAddress:     map[common.Address]*rpstate.NativeNodeDetails{},
+		MinipoolDetails:          b.MinipoolDetails,
+		MinipoolDetailsByAddress: map[common.Address]*rpstate.NativeMinipoolDetails{},
+		MinipoolDetailsByNode:    map[common.Address][]*rpstate.NativeMinipoolDetails{},
+		ValidatorDetails:         b.ValidatorDetails,
+	}
+	if networkState.ValidatorDetails == nil {
+		networkState.ValidatorDetails = map[rptypes.ValidatorPubkey]beacon.ValidatorStatus{}
+	}
+	for i, details := range networkState.NodeDetails {
+		networkState.NodeDetailsByAddress[details.NodeAddress] = &networkState.NodeDetails[i]
+	}
+	for i, details := range networkState.MinipoolDetails {
+		networkState.MinipoolDetailsByAddress[details.MinipoolAddress] = &networkState.MinipoolDetails[i]
+		networkState.MinipoolDetailsByNode[details.NodeAddress] = append(networkState.MinipoolDetailsByNode[details.NodeAddress], &networkState.MinipoolDetails[i])
+	}
+	return networkState
+
+}
+
+// Read the header of a snapshot file
+func readStateSnapshotHeader(path string) (stateSnapshotHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return stateSnapshotHeader{}, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return stateSnapshotHeader{}, fmt.Errorf("error decompressing: %w", err)
+	}
+	var header stateSnapshotHeader
+	err = gob.NewDecoder(reader).Decode(&header)
+	if err != nil {
+		return stateSnapshotHeader{}, fmt.Errorf("error decoding header: %w", err)
+	}
+	return header, nil
+}
+
+// Get the names of the snapshot and leftover temporary files in the cache directory
+func (c *stateCache) getFiles() ([]string, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache directory %s: %w", c.dir, err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if strings.HasSuffix(name, stateSnapshotExtension) || strings.HasSuffix(name, stateSnapshotTempExt) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"strconv"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/rocket-pool/rocketpool-go/rocketpool"
 	rptypes "github.com/rocket-pool/rocketpool-go/types"
@@ -18,20 +21,23 @@ import (
 	"github.com/rocket-pool/smartnode/shared/services/config"
 	rprewards "github.com/rocket-pool/smartnode/shared/services/rewards"
 	"github.com/rocket-pool/smartnode/shared/services/state"
+	cfgtypes "github.com/rocket-pool/smartnode/shared/types/config"
 	"github.com/rocket-pool/smartnode/shared/utils/eth1"
 	"github.com/rocket-pool/smartnode/shared/utils/log"
 )
 
 // Submit network balances task
 type submitNetworkBalances struct {
-	c      *cli.Context
-	log    log.ColorLogger
-	errLog log.ColorLogger
-	cfg    *config.RocketPoolConfig
-	ec     rocketpool.ExecutionClient
-	rp     *rocketpool.RocketPool
-	bc     beacon.Client
-	mgr    *state.NetworkStateManager
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	cfg          *config.RocketPoolConfig
+	ec           rocketpool.ExecutionClient
+	rp           *rocketpool.RocketPool
+	bc           beacon.Client
+	mgr          *stateManager
+	outputFormat string
+	snapshot     *dutySnapshot
 }
 
 // Network balance info
@@ -45,15 +51,71 @@ type networkBalances struct {
 	RETHContract          *big.Int
 	RETHSupply            *big.Int
 	NodeCreditBalance     *big.Int
+	Minipools             []minipoolBalanceDetails
+	Nodes                 []nodeBalanceDetails
+	StateLoadTime         time.Duration
 }
+
+// Machine-readable network balance report, with all balances as decimal wei strings
+type networkBalancesOutput struct {
+	Network               string  `json:"network"`
+	ElBlock               uint64  `json:"elBlock"`
+	BeaconSlot            uint64  `json:"beaconSlot"`
+	DepositPool           string  `json:"depositPool"`
+	MinipoolsTotal        string  `json:"minipoolsTotal"`
+	MinipoolsStaking      string  `json:"minipoolsStaking"`
+	DistributorShareTotal string  `json:"distributorShareTotal"`
+	SmoothingPoolShare    string  `json:"smoothingPoolShare"`
+	RETHContract          string  `json:"rethContract"`
+	RETHSupply            string  `json:"rethSupply"`
+	NodeCreditBalance     string  `json:"nodeCreditBalance"`
+	TotalEth              string  `json:"totalEth"`
+	RETHRatio             float64 `json:"rethRatio"`
+}
+
+// The rule used to determine a minipool's contribution to the user balance
+type minipoolBalanceBranch string
+
+const (
+	minipoolBranchVacant      minipoolBalanceBranch = "vacant"
+	minipoolBranchDissolved   minipoolBalanceBranch = "dissolved"
+	minipoolBranchPrelaunch   minipoolBalanceBranch = "initialized-or-prelaunch"
+	minipoolBranchBrokenLeb   minipoolBalanceBranch = "broken-redstone-leb"
+	minipoolBranchNotActive   minipoolBalanceBranch = "not-active"
+	minipoolBranchRefundQueue minipoolBalanceBranch = "full-refund-queue"
+	minipoolBranchStaking     minipoolBalanceBranch = "staking"
+)
+
 type minipoolBalanceDetails struct {
+	Address     common.Address
+	NodeAddress common.Address
+	Pubkey      rptypes.ValidatorPubkey
+	Status      rptypes.MinipoolStatus
+	DepositType rptypes.MinipoolDeposit
+	Version     uint8
+	Branch      minipoolBalanceBranch
 	IsStaking   bool
 	UserBalance *big.Int
 }
 
+// A node's contribution to the distributor share and node credit balances
+type nodeBalanceDetails struct {
+	Address               common.Address
+	FeeDistributorAddress common.Address
+	DistributorBalance    *big.Int
+	DistributorUserShare  *big.Int
+	DepositCreditBalance  *big.Int
+	InSmoothingPool       bool
+}
+
 // Create submit network balances task
 func newSubmitNetworkBalances(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*submitNetworkBalances, error) {
 
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+
 	ec, bc, rp, cfg, mgr, err := initialize(c, logger)
 	if err != nil {
 		return nil, fmt.Errorf("error initializing RP artifacts: %w", err)
@@ -61,6 +123,46 @@ func newSubmitNetworkBalances(c *cli.Context, logger log.ColorLogger, errorLogge
 
 	// Return task
 	return &submitNetworkBalances{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		cfg:          cfg,
+		ec:           ec,
+		rp:           rp,
+		bc:           bc,
+		mgr:          mgr,
+		outputFormat: outputFormat,
+	}, nil
+
+}
+
+// Create submit network balances task that replays the snapshot from the --from-snapshot option without any clients
+func newSubmitNetworkBalancesFromSnapshot(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*submitNetworkBalances, error) {
+
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, cfg, err := loadDutySnapshot(c, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return task
+	return &submitNetworkBalances{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		cfg:          cfg,
+		outputFormat: outputFormat,
+		snapshot:     snapshot,
+	}, nil
+
+}
+
+// Create the price and balance tasks for commands that simulate both duties with the same artifacts
+func newDutyTasks(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger, ec rocketpool.ExecutionClient, bc beacon.Client, rp *rocketpool.RocketPool, cfg *config.RocketPoolConfig, mgr *stateManager) (*submitRplPrice, *submitNetworkBalances) {
+	price := &submitRplPrice{
 		c:      c,
 		log:    logger,
 		errLog: errorLogger,
@@ -69,42 +171,32 @@ func newSubmitNetworkBalances(c *cli.Context, logger log.ColorLogger, errorLogge
 		rp:     rp,
 		bc:     bc,
 		mgr:    mgr,
-	}, nil
-
+	}
+	balances := &submitNetworkBalances{
+		c:      c,
+		log:    logger,
+		errLog: errorLogger,
+		cfg:    cfg,
+		ec:     ec,
+		rp:     rp,
+		bc:     bc,
+		mgr:    mgr,
+	}
+	return price, balances
 }
 
 // Submit network balances
 func (t *submitNetworkBalances) run() error {
 
 	var state *state.NetworkState
-	if t.c.IsSet("target-block") {
-		// Get the time of the block
-		blockNumber := t.c.Uint64("target-block")
-		header, err := t.ec.HeaderByNumber(context.Background(), big.NewInt(0).SetUint64(blockNumber))
-		if err != nil {
-			return err
-		}
-		blockTime := time.Unix(int64(header.Time), 0)
-
-		// Get the Beacon block corresponding to this time
-		eth2Config, err := t.bc.GetEth2Config()
-		if err != nil {
-			return fmt.Errorf("error getting beacon config: %w", err)
-		}
-		genesisTime := time.Unix(int64(eth2Config.GenesisTime), 0)
-		timeSinceGenesis := blockTime.Sub(genesisTime)
-		slotNumber := uint64(timeSinceGenesis.Seconds()) / eth2Config.SecondsPerSlot
-		state, err = t.mgr.GetStateForSlot(slotNumber)
-		if err != nil {
-			return fmt.Errorf("error getting state for EL block %d, CL slot %d: %w", blockNumber, slotNumber, err)
-		}
+	var err error
+	if t.snapshot != nil {
+		state, err = t.snapshot.getTargetState(t.c, t.log)
 	} else {
-		t.log.Printlnf("Target block not set, getting the state of the chain head.")
-		var err error
-		state, err = t.mgr.GetHeadState()
-		if err != nil {
-			return fmt.Errorf("error getting network state for head slot: %w", err)
-		}
+		state, err = getTargetState(t.c, t.ec, t.mgr, t.log)
+	}
+	if err != nil {
+		return err
 	}
 
 	// Check balance submission
@@ -114,18 +206,19 @@ func (t *submitNetworkBalances) run() error {
 	}
 
 	// Get block to submit balances for
-	//blockNumberBig := state.NetworkDetails.LatestReportableBalancesBlock
-	blockNumber := state.ElBlockNumber
-	blockNumberBig := big.NewInt(int64(blockNumber))
+	blockNumber := getDutyBlock(t.c, t.log, state.ElBlockNumber, state.NetworkDetails.LatestReportableBalancesBlock.Uint64())
 	t.log.Printlnf("Calculating network balances for block %d...", blockNumber)
 
 	// Get network balances at block
-	header, err := t.ec.HeaderByNumber(context.Background(), blockNumberBig)
-	blockTime := time.Unix(int64(header.Time), 0)
-	balances, err := t.getNetworkBalances(header, blockNumberBig, state.BeaconSlotNumber, blockTime, state.IsAtlasDeployed)
+	balances, slotNumber, err := t.getNetworkBalancesForBlock(blockNumber)
 	if err != nil {
 		t.errLog.Println(err.Error())
 		t.errLog.Println("*** Balance report failed. ***")
+
+		// Consumers of the JSON report need a non-zero exit status to tell a failure apart from a missing report
+		if t.outputFormat == outputFormatJson {
+			return fmt.Errorf("error calculating network balances for block %d: %w", blockNumber, err)
+		}
 		return nil
 	}
 
@@ -140,18 +233,39 @@ func (t *submitNetworkBalances) run() error {
 	t.log.Printlnf("rETH token supply: %s wei", balances.RETHSupply.String())
 
 	// Calculate total ETH balance
-	totalEth := big.NewInt(0)
-	totalEth.Sub(totalEth, balances.NodeCreditBalance)
-	totalEth.Add(totalEth, balances.DepositPool)
-	totalEth.Add(totalEth, balances.MinipoolsTotal)
-	totalEth.Add(totalEth, balances.RETHContract)
-	totalEth.Add(totalEth, balances.DistributorShareTotal)
-	totalEth.Add(totalEth, balances.SmoothingPoolShare)
-
-	ratio := eth.WeiToEth(totalEth) / eth.WeiToEth(balances.RETHSupply)
+	totalEth := balances.getTotalEth()
+	ratio := balances.getRETHRatio()
 	t.log.Printlnf("Total ETH = %s\n", totalEth)
 	t.log.Printlnf("Calculated ratio = %.6f\n", ratio)
 
+	// Write the per-minipool breakdown
+	if t.c.IsSet("breakdown") {
+		breakdownPath := t.c.String("breakdown")
+		err = writeMinipoolBreakdown(breakdownPath, balances.Minipools)
+		if err != nil {
+			return err
+		}
+		t.log.Printlnf("Wrote the breakdown of %d minipools to %s.", len(balances.Minipools), breakdownPath)
+	}
+
+	// Write the per-node breakdown
+	if t.c.IsSet("node-breakdown") {
+		breakdownPath := t.c.String("node-breakdown")
+		err = writeNodeBreakdown(breakdownPath, balances.Nodes)
+		if err != nil {
+			return err
+		}
+		t.log.Printlnf("Wrote the breakdown of %d nodes to %s.", len(balances.Nodes), breakdownPath)
+	}
+
+	// Print the machine-readable report
+	if t.outputFormat == outputFormatJson {
+		err = printJson(t.getNetworkBalancesOutput(balances, slotNumber))
+		if err != nil {
+			return err
+		}
+	}
+
 	// Log and return
 	t.log.Println("Balance report complete.")
 
@@ -160,31 +274,203 @@ func (t *submitNetworkBalances) run() error {
 
 }
 
+// Get the machine-readable report for a set of network balances
+func (t *submitNetworkBalances) getNetworkBalancesOutput(balances networkBalances, slotNumber uint64) networkBalancesOutput {
+	totalEth := balances.getTotalEth()
+	return networkBalancesOutput{
+		Network:               string(t.cfg.Smartnode.Network.Value.(cfgtypes.Network)),
+		ElBlock:               balances.Block,
+		BeaconSlot:            slotNumber,
+		DepositPool:           balances.DepositPool.String(),
+		MinipoolsTotal:        balances.MinipoolsTotal.String(),
+		MinipoolsStaking:      balances.MinipoolsStaking.String(),
+		DistributorShareTotal: balances.DistributorShareTotal.String(),
+		SmoothingPoolShare:    balances.SmoothingPoolShare.String(),
+		RETHContract:          balances.RETHContract.String(),
+		RETHSupply:            balances.RETHSupply.String(),
+		NodeCreditBalance:     balances.NodeCreditBalance.String(),
+		TotalEth:              totalEth.String(),
+		RETHRatio:             balances.getRETHRatio(),
+	}
+}
+
+// Write the per-minipool balance details to a CSV file
+func writeMinipoolBreakdown(path string, minipools []minipoolBalanceDetails) error {
+	header := []string{"address", "node", "pubkey", "status", "depositType", "delegateVersion", "branch", "userBalance", "isStaking"}
+	records := make([][]string, len(minipools))
+	for i, mp := range minipools {
+		records[i] = []string{
+			mp.Address.Hex(),
+			mp.NodeAddress.Hex(),
+			mp.Pubkey.Hex(),
+			mp.Status.String(),
+			mp.DepositType.String(),
+			strconv.FormatUint(uint64(mp.Version), 10),
+			string(mp.Branch),
+			mp.UserBalance.String(),
+			strconv.FormatBool(mp.IsStaking),
+		}
+	}
+	return writeCsvFile(path, header, records)
+}
+
+// Write the per-node balance details to a CSV file
+func writeNodeBreakdown(path string, nodes []nodeBalanceDetails) error {
+	header := []string{"node", "feeDistributor", "distributorBalance", "distributorUserShare", "depositCredit", "inSmoothingPool"}
+	records := make([][]string, len(nodes))
+	for i, node := range nodes {
+		records[i] = []string{
+			node.Address.Hex(),
+			node.FeeDistributorAddress.Hex(),
+			node.DistributorBalance.String(),
+			node.DistributorUserShare.String(),
+			node.DepositCreditBalance.String(),
+			strconv.FormatBool(node.InSmoothingPool),
+		}
+	}
+	return writeCsvFile(path, header, records)
+}
+
+// Get the total ETH backing rETH
+func (b *networkBalances) getTotalEth() *big.Int {
+	totalEth := big.NewInt(0)
+	totalEth.Sub(totalEth, b.NodeCreditBalance)
+	totalEth.Add(totalEth, b.DepositPool)
+	totalEth.Add(totalEth, b.MinipoolsTotal)
+	totalEth.Add(totalEth, b.RETHContract)
+	totalEth.Add(totalEth, b.DistributorShareTotal)
+	totalEth.Add(totalEth, b.SmoothingPoolShare)
+	return totalEth
+}
+
+// Get the ETH value of 1 rETH. Like the rETH contract, this is 1 if no rETH has been minted.
+func (b *networkBalances) getRETHRatio() float64 {
+	if b.RETHSupply.Sign() == 0 {
+		return 1
+	}
+	return eth.WeiToEth(b.getTotalEth()) / eth.WeiToEth(b.RETHSupply)
+}
+
 // Prints a message to the log
 func (t *submitNetworkBalances) printMessage(message string) {
 	t.log.Println(message)
 }
 
+// Get the network balances for an EL block, using the Beacon slot that corresponds to its timestamp
+func (t *submitNetworkBalances) getNetworkBalancesForBlock(blockNumber uint64) (networkBalances, uint64, error) {
+
+	// Use the captured state when replaying a snapshot
+	if t.snapshot != nil {
+		return t.getNetworkBalancesFromSnapshot(blockNumber)
+	}
+
+	// Get the time of the block
+	blockNumberBig := big.NewInt(0).SetUint64(blockNumber)
+	header, err := t.ec.HeaderByNumber(context.Background(), blockNumberBig)
+	if err != nil {
+		return networkBalances{}, 0, fmt.Errorf("error getting header for EL block %d: %w", blockNumber, err)
+	}
+	blockTime := time.Unix(int64(header.Time), 0)
+
+	// Get the Beacon block corresponding to this time
+	slotNumber := getBeaconSlotForBlock(header, t.mgr.BeaconConfig)
+
+	// Check if Atlas was deployed at this block
+	isAtlasDeployed, err := state.IsAtlasDeployed(t.rp, &bind.CallOpts{BlockNumber: blockNumberBig})
+	if err != nil {
+		return networkBalances{}, 0, fmt.Errorf("error checking if Atlas is deployed at EL block %d: %w", blockNumber, err)
+	}
+
+	balances, err := t.getNetworkBalances(header, blockNumberBig, slotNumber, blockTime, isAtlasDeployed)
+	if err != nil {
+		return networkBalances{}, 0, err
+	}
+	return balances, slotNumber, nil
+
+}
+
+// Get the network balances for a block from the snapshot being replayed, along with the Beacon slot they were
+// calculated at
+func (t *submitNetworkBalances) getNetworkBalancesFromSnapshot(blockNumber uint64) (networkBalances, uint64, error) {
+
+	snapshot := t.snapshot.Balances
+	if snapshot == nil {
+		return networkBalances{}, 0, fmt.Errorf("snapshot doesn't include network balances (balance submissions were disabled when it was captured)")
+	}
+	if snapshot.Block != blockNumber {
+		return networkBalances{}, 0, fmt.Errorf("snapshot has network balances for block %d, not %d", snapshot.Block, blockNumber)
+	}
+	header, err := snapshot.getHeader()
+	if err != nil {
+		return networkBalances{}, 0, err
+	}
+	state := t.snapshot.TargetState.getNetworkState()
+	if snapshot.State != nil {
+		state = snapshot.State.getNetworkState()
+	}
+
+	blockTime := time.Unix(int64(header.Time), 0)
+	balances, err := t.getNetworkBalancesFromState(nil, state, header, snapshot.Slot, blockTime, snapshot.IsAtlasDeployed)
+	if err != nil {
+		return networkBalances{}, 0, err
+	}
+	return balances, snapshot.Slot, nil
+
+}
+
 // Get the network balances at a specific block
 func (t *submitNetworkBalances) getNetworkBalances(elBlockHeader *types.Header, elBlock *big.Int, beaconBlock uint64, slotTime time.Time, isAtlasDeployed bool) (networkBalances, error) {
 
-	// Get a client with the block number available
-	client, err := eth1.GetBestApiClient(t.rp, t.cfg, t.printMessage, elBlock)
+	// Create a new state for the target block
+	start := time.Now()
+	client, state, err := t.getStateForBalances(elBlock, beaconBlock)
 	if err != nil {
 		return networkBalances{}, err
 	}
+	stateLoadTime := time.Since(start)
 
-	// Create a new state gen manager
-	mgr, err := state.NewNetworkStateManager(client, t.cfg, client.Client, t.bc, &t.log)
+	balances, err := t.getNetworkBalancesFromState(client, state, elBlockHeader, beaconBlock, slotTime, isAtlasDeployed)
 	if err != nil {
-		return networkBalances{}, fmt.Errorf("error creating network state manager for EL block %s, Beacon slot %d: %w", elBlock, beaconBlock, err)
+		return networkBalances{}, err
+	}
+	balances.StateLoadTime = stateLoadTime
+	return balances, nil
+
+}
+
+// Get the network state for a balances report, along with a client that has the block available
+func (t *submitNetworkBalances) getStateForBalances(elBlock *big.Int, beaconBlock uint64) (*rocketpool.RocketPool, *state.NetworkState, error) {
+
+	// Get a client with the block number available
+	client, err := eth1.GetBestApiClient(t.rp, t.cfg, t.printMessage, elBlock)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Reuse the state manager unless a different client is required for this block
+	mgr := t.mgr
+	if client != t.rp {
+		networkStateManager, err := state.NewNetworkStateManager(client, t.cfg, client.Client, t.bc, &t.log)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating network state manager for EL block %s, Beacon slot %d: %w", elBlock, beaconBlock, err)
+		}
+		mgr = &stateManager{
+			NetworkStateManager: networkStateManager,
+			ec:                  client.Client,
+			cache:               t.mgr.cache,
+		}
 	}
 
-	// Create a new state for the target block
 	state, err := mgr.GetStateForSlot(beaconBlock)
 	if err != nil {
-		return networkBalances{}, fmt.Errorf("couldn't get network state for EL block %s, Beacon slot %d: %w", elBlock, beaconBlock, err)
+		return nil, nil, fmt.Errorf("couldn't get network state for EL block %s, Beacon slot %d: %w", elBlock, beaconBlock, err)
 	}
+	return client, state, nil
+
+}
+
+// Get the network balances from a network state that has already been loaded
+func (t *submitNetworkBalances) getNetworkBalancesFromState(client *rocketpool.RocketPool, state *state.NetworkState, elBlockHeader *types.Header, beaconBlock uint64, slotTime time.Time, isAtlasDeployed bool) (networkBalances, error) {
 
 	// Data
 	var wg errgroup.Group
@@ -224,6 +510,12 @@ func (t *submitNetworkBalances) getNetworkBalances(elBlockHeader *types.Header,
 	// Get the smoothing pool user share
 	wg.Go(func() error {
 
+		// Use the captured share when replaying a snapshot, since approximating it requires the clients
+		if t.snapshot != nil {
+			smoothingPoolShare = t.snapshot.Balances.SmoothingPoolShare
+			return nil
+		}
+
 		// Get the current interval
 		currentIndex := state.NetworkDetails.RewardIndex
 
@@ -265,6 +557,7 @@ func (t *submitNetworkBalances) getNetworkBalances(elBlockHeader *types.Header,
 		RETHContract:          rethContractBalance,
 		RETHSupply:            rethTotalSupply,
 		NodeCreditBalance:     big.NewInt(0),
+		Minipools:             mpBalanceDetails,
 	}
 
 	// Add minipool balances
@@ -287,6 +580,23 @@ func (t *submitNetworkBalances) getNetworkBalances(elBlockHeader *types.Header,
 		balances.DistributorShareTotal.Add(balances.DistributorShareTotal, share)
 	}
 
+	// Get the per-node details
+	balances.Nodes = make([]nodeBalanceDetails, len(state.NodeDetails))
+	for i, node := range state.NodeDetails {
+		depositCredit := big.NewInt(0)
+		if state.IsAtlasDeployed {
+			depositCredit = node.DepositCreditBalance
+		}
+		balances.Nodes[i] = nodeBalanceDetails{
+			Address:               node.NodeAddress,
+			FeeDistributorAddress: node.FeeDistributorAddress,
+			DistributorBalance:    node.DistributorBalance,
+			DistributorUserShare:  distributorShares[i],
+			DepositCreditBalance:  depositCredit,
+			InSmoothingPool:       node.SmoothingPoolRegistrationState,
+		}
+	}
+
 	// Return
 	return balances, nil
 
@@ -302,25 +612,34 @@ func (t *submitNetworkBalances) getMinipoolBalanceDetails(mpd *rpstate.NativeMin
 
 	blockEpoch := state.BeaconSlotNumber / state.BeaconConfig.SlotsPerEpoch
 
+	details := minipoolBalanceDetails{
+		Address:     mpd.MinipoolAddress,
+		NodeAddress: mpd.NodeAddress,
+		Pubkey:      mpd.Pubkey,
+		Status:      status,
+		DepositType: mpType,
+		Version:     mpd.Version,
+	}
+
 	// Ignore vacant minipools
 	if mpd.IsVacant {
-		return minipoolBalanceDetails{
-			UserBalance: big.NewInt(0),
-		}
+		details.Branch = minipoolBranchVacant
+		details.UserBalance = big.NewInt(0)
+		return details
 	}
 
 	// Dissolved minipools don't contribute to rETH
 	if status == rptypes.Dissolved {
-		return minipoolBalanceDetails{
-			UserBalance: big.NewInt(0),
-		}
+		details.Branch = minipoolBranchDissolved
+		details.UserBalance = big.NewInt(0)
+		return details
 	}
 
 	// Use user deposit balance if initialized or prelaunch
 	if status == rptypes.Initialized || status == rptypes.Prelaunch {
-		return minipoolBalanceDetails{
-			UserBalance: userDepositBalance,
-		}
+		details.Branch = minipoolBranchPrelaunch
+		details.UserBalance = userDepositBalance
+		return details
 	}
 
 	// "Broken" LEBs with the Redstone delegates report their total balance minus their node deposit balance
@@ -329,31 +648,29 @@ func (t *submitNetworkBalances) getMinipoolBalanceDetails(mpd *rpstate.NativeMin
 		brokenBalance.Add(brokenBalance, eth.GweiToWei(float64(validator.Balance)))
 		brokenBalance.Sub(brokenBalance, mpd.NodeRefundBalance)
 		brokenBalance.Sub(brokenBalance, mpd.NodeDepositBalance)
-		return minipoolBalanceDetails{
-			IsStaking:   (validator.Exists && validator.ActivationEpoch < blockEpoch && validator.ExitEpoch > blockEpoch),
-			UserBalance: brokenBalance,
-		}
+		details.Branch = minipoolBranchBrokenLeb
+		details.IsStaking = (validator.Exists && validator.ActivationEpoch < blockEpoch && validator.ExitEpoch > blockEpoch)
+		details.UserBalance = brokenBalance
+		return details
 	}
 
 	// Use user deposit balance if validator not yet active on beacon chain at block
 	if !validator.Exists || validator.ActivationEpoch >= blockEpoch {
-		return minipoolBalanceDetails{
-			UserBalance: userDepositBalance,
-		}
+		details.Branch = minipoolBranchNotActive
+		details.UserBalance = userDepositBalance
+		return details
 	}
 
 	// Here userBalance is CalculateUserShare(beaconBalance + minipoolBalance - refund)
 	userBalance := mpd.UserShareOfBalanceIncludingBeacon
+	details.IsStaking = (validator.ExitEpoch > blockEpoch)
 	if userDepositBalance.Cmp(big.NewInt(0)) == 0 && mpType == rptypes.Full {
-		return minipoolBalanceDetails{
-			IsStaking:   (validator.ExitEpoch > blockEpoch),
-			UserBalance: big.NewInt(0).Sub(userBalance, eth.EthToWei(16)), // Remove 16 ETH from the user balance for full minipools in the refund queue
-		}
+		details.Branch = minipoolBranchRefundQueue
+		details.UserBalance = big.NewInt(0).Sub(userBalance, eth.EthToWei(16)) // Remove 16 ETH from the user balance for full minipools in the refund queue
 	} else {
-		return minipoolBalanceDetails{
-			IsStaking:   (validator.ExitEpoch > blockEpoch),
-			UserBalance: userBalance,
-		}
+		details.Branch = minipoolBranchStaking
+		details.UserBalance = userBalance
 	}
+	return details
 
 }
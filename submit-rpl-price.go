@@ -1,11 +1,9 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"math/big"
 	"strings"
-	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 
@@ -59,14 +57,15 @@ type poolObserveResponse struct {
 
 // Submit RPL price task
 type submitRplPrice struct {
-	c      *cli.Context
-	log    log.ColorLogger
-	errLog log.ColorLogger
-	cfg    *config.RocketPoolConfig
-	ec     rocketpool.ExecutionClient
-	rp     *rocketpool.RocketPool
-	bc     beacon.Client
-	mgr    *state.NetworkStateManager
+	c        *cli.Context
+	log      log.ColorLogger
+	errLog   log.ColorLogger
+	cfg      *config.RocketPoolConfig
+	ec       rocketpool.ExecutionClient
+	rp       *rocketpool.RocketPool
+	bc       beacon.Client
+	mgr      *stateManager
+	snapshot *dutySnapshot
 }
 
 // Create submit RPL price task
@@ -91,38 +90,37 @@ func newSubmitRplPrice(c *cli.Context, logger log.ColorLogger, errorLogger log.C
 
 }
 
+// Create submit RPL price task that replays the snapshot from the --from-snapshot option without any clients
+func newSubmitRplPriceFromSnapshot(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*submitRplPrice, error) {
+
+	snapshot, cfg, err := loadDutySnapshot(c, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return task
+	return &submitRplPrice{
+		c:        c,
+		log:      logger,
+		errLog:   errorLogger,
+		cfg:      cfg,
+		snapshot: snapshot,
+	}, nil
+
+}
+
 // Submit RPL price
 func (t *submitRplPrice) run() error {
 
 	var state *state.NetworkState
-	if t.c.IsSet("target-block") {
-		// Get the time of the block
-		blockNumber := t.c.Uint64("target-block")
-		header, err := t.ec.HeaderByNumber(context.Background(), big.NewInt(0).SetUint64(blockNumber))
-		if err != nil {
-			return err
-		}
-		blockTime := time.Unix(int64(header.Time), 0)
-
-		// Get the Beacon block corresponding to this time
-		eth2Config, err := t.bc.GetEth2Config()
-		if err != nil {
-			return fmt.Errorf("error getting beacon config: %w", err)
-		}
-		genesisTime := time.Unix(int64(eth2Config.GenesisTime), 0)
-		timeSinceGenesis := blockTime.Sub(genesisTime)
-		slotNumber := uint64(timeSinceGenesis.Seconds()) / eth2Config.SecondsPerSlot
-		state, err = t.mgr.GetStateForSlot(slotNumber)
-		if err != nil {
-			return fmt.Errorf("error getting state for EL block %d, CL slot %d: %w", blockNumber, slotNumber, err)
-		}
+	var err error
+	if t.snapshot != nil {
+		state, err = t.snapshot.getTargetState(t.c, t.log)
 	} else {
-		t.log.Printlnf("Target block not set, getting the state of the chain head.")
-		var err error
-		state, err = t.mgr.GetHeadState()
-		if err != nil {
-			return fmt.Errorf("error getting network state for head slot: %w", err)
-		}
+		state, err = getTargetState(t.c, t.ec, t.mgr, t.log)
+	}
+	if err != nil {
+		return err
 	}
 
 	// Check if submission is enabled
@@ -132,8 +130,7 @@ func (t *submitRplPrice) run() error {
 	}
 
 	// Get block to submit price for
-	//blockNumber := state.NetworkDetails.LatestReportablePricesBlock
-	blockNumber := state.ElBlockNumber
+	blockNumber := getDutyBlock(t.c, t.log, state.ElBlockNumber, state.NetworkDetails.LatestReportablePricesBlock)
 	t.log.Printlnf("Getting RPL price for block %d...", blockNumber)
 
 	// Get RPL price at block
@@ -158,6 +155,22 @@ func (t *submitRplPrice) run() error {
 // Get RPL price via TWAP at block
 func (t *submitRplPrice) getRplTwap(blockNumber uint64) (*big.Int, error) {
 
+	// Use the captured pool observation when replaying a snapshot
+	if t.snapshot != nil {
+		return t.snapshot.getRplPrice(blockNumber)
+	}
+
+	response, _, err := t.getRplTwapObservation(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	return getRplPriceFromObservation(response, twapNumberOfSeconds), nil
+
+}
+
+// Get the TWAP pool's tick cumulatives over the TWAP interval at block, along with the pool's address
+func (t *submitRplPrice) getRplTwapObservation(blockNumber uint64) (poolObserveResponse, common.Address, error) {
+
 	// Initialize call options
 	opts := &bind.CallOpts{
 		BlockNumber: big.NewInt(int64(blockNumber)),
@@ -165,19 +178,19 @@ func (t *submitRplPrice) getRplTwap(blockNumber uint64) (*big.Int, error) {
 
 	poolAddress := t.cfg.Smartnode.GetRplTwapPoolAddress()
 	if poolAddress == "" {
-		return nil, fmt.Errorf("RPL TWAP pool contract not deployed on this network")
+		return poolObserveResponse{}, common.Address{}, fmt.Errorf("RPL TWAP pool contract not deployed on this network")
 	}
 
 	// Get a client with the block number available
 	client, err := eth1.GetBestApiClient(t.rp, t.cfg, t.printMessage, opts.BlockNumber)
 	if err != nil {
-		return nil, err
+		return poolObserveResponse{}, common.Address{}, err
 	}
 
 	// Construct the pool contract instance
 	parsed, err := abi.JSON(strings.NewReader(RplTwapPoolAbi))
 	if err != nil {
-		return nil, fmt.Errorf("error decoding RPL TWAP pool ABI: %w", err)
+		return poolObserveResponse{}, common.Address{}, fmt.Errorf("error decoding RPL TWAP pool ABI: %w", err)
 	}
 	addr := common.HexToAddress(poolAddress)
 	t.log.Printlnf("TWAP Address: %s", addr.Hex())
@@ -197,8 +210,14 @@ func (t *submitRplPrice) getRplTwap(blockNumber uint64) (*big.Int, error) {
 
 	err = pool.Call(opts, &response, "observe", args)
 	if err != nil {
-		return nil, fmt.Errorf("could not get RPL price at block %d: %w", blockNumber, err)
+		return poolObserveResponse{}, common.Address{}, fmt.Errorf("could not get RPL price at block %d: %w", blockNumber, err)
 	}
+	return response, addr, nil
+
+}
+
+// Get the RPL price from the TWAP pool's tick cumulatives over an interval
+func getRplPriceFromObservation(response poolObserveResponse, interval uint32) *big.Int {
 
 	tick := big.NewInt(0).Sub(response.TickCumulatives[1], response.TickCumulatives[0])
 	tick.Div(tick, big.NewInt(int64(interval))) // tick = (cumulative[1] - cumulative[0]) / interval
@@ -216,7 +235,7 @@ func (t *submitRplPrice) getRplTwap(blockNumber uint64) (*big.Int, error) {
 	rplPrice := big.NewInt(0).Div(numerator, denominator) // 1e18 ^ 2 / (1.0001e18^tick * 1e18 / 1e18^tick)
 
 	// Return
-	return rplPrice, nil
+	return rplPrice
 
 }
 
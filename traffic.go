@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/urfave/cli/v2"
+)
+
+// Traffic transport modes
+const (
+	trafficModeRecord string = "record"
+	trafficModeReplay string = "replay"
+)
+
+// The endpoint used for the EC and BN when replaying traffic without them; it's never contacted
+const trafficReplayUrl string = "http://replay.invalid"
+
+// An HTTP transport that stores every request and response in a directory, or serves them back from it.
+// Requests are identified by their method, path and body, so the same traffic replays regardless of which endpoint it
+// was recorded from. JSON-RPC IDs are ignored when matching requests and rewritten in replayed responses, since they
+// depend on the order concurrent requests were sent in.
+type trafficTransport struct {
+	mode    string
+	dir     string
+	service string
+	next    http.RoundTripper
+
+	// If set, only requests to this host are recorded or replayed; the rest are sent by next untouched
+	host string
+
+	// How many times each request has been seen, so repeated requests replay their responses in order
+	lock   *sync.Mutex
+	counts map[string]int
+}
+
+// A stored request and its response
+type recordedExchange struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Request     string `json:"request"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	Response    string `json:"response"`
+}
+
+// Create the transport for the --record or --replay option, or nil if neither is set
+func newTrafficTransport(c *cli.Context) (*trafficTransport, error) {
+
+	var mode string
+	var dir string
+	switch {
+	case c.IsSet("record") && c.IsSet("replay"):
+		return nil, fmt.Errorf("record and replay can't be used together")
+	case c.IsSet("record"):
+		mode = trafficModeRecord
+		dir = c.String("record")
+	case c.IsSet("replay"):
+		mode = trafficModeReplay
+		dir = c.String("replay")
+	default:
+		return nil, nil
+	}
+
+	if mode == trafficModeReplay {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("error opening replay directory: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("replay directory %s is not a directory", dir)
+		}
+	}
+
+	return &trafficTransport{
+		mode:   mode,
+		dir:    dir,
+		next:   http.DefaultTransport,
+		lock:   &sync.Mutex{},
+		counts: map[string]int{},
+	}, nil
+
+}
+
+// Get a copy of the transport that stores the traffic of a service (e.g. "ec" or "bn") in its own subdirectory
+func (t *trafficTransport) forService(service string) *trafficTransport {
+	copy := *t
+	copy.service = service
+	return &copy
+}
+
+// Get a copy of the transport for a service that only records or replays the requests sent to the host of its URL
+func (t *trafficTransport) forServiceUrl(service string, serviceUrl string) (*trafficTransport, error) {
+	parsedUrl, err := url.Parse(serviceUrl)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s URL: %w", strings.ToUpper(service), err)
+	}
+	copy := t.forService(service)
+	copy.host = parsedUrl.Host
+	return copy, nil
+}
+
+// Connect to an EC over HTTP, sending its requests through a traffic transport
+func dialRecordedEc(ecUrl string, transport *trafficTransport) (*ethclient.Client, error) {
+	parsedUrl, err := url.Parse(ecUrl)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing EC URL: %w", err)
+	}
+	if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
+		return nil, fmt.Errorf("recording and replaying EC traffic requires an http or https ec-endpoint, not %s", parsedUrl.Scheme)
+	}
+	client, err := rpc.DialHTTPWithClient(ecUrl, &http.Client{Transport: transport})
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(client), nil
+}
+
+// Record or replay a request
+func (t *trafficTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+
+	// Leave requests to other services alone
+	if t.host != "" && request.URL.Host != t.host {
+		return t.next.RoundTrip(request)
+	}
+
+	// Read the request body so it can be matched, then restore it for the real request
+	var requestBody []byte
+	if request.Body != nil {
+		var err error
+		requestBody, err = io.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading request body: %w", err)
+		}
+		request.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	// Get the file for this occurrence of the request
+	key := t.getRequestKey(request, requestBody)
+	t.lock.Lock()
+	occurrence := t.counts[key]
+	t.counts[key]++
+	t.lock.Unlock()
+	serviceDir := filepath.Join(t.dir, t.service)
+
+	if t.mode == trafficModeReplay {
+		exchange, err := t.loadExchange(serviceDir, key, occurrence)
+		if err != nil {
+			return nil, fmt.Errorf("error replaying %s %s: %w", request.Method, request.URL.RequestURI(), err)
+		}
+		responseBody, err := replaceJsonRpcIds([]byte(exchange.Request), requestBody, []byte(exchange.Response))
+		if err != nil {
+			return nil, fmt.Errorf("error replaying %s %s: %w", request.Method, request.URL.RequestURI(), err)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", exchange.Status, http.StatusText(exchange.Status)),
+			StatusCode:    exchange.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": []string{exchange.ContentType}},
+			Body:          io.NopCloser(bytes.NewReader(responseBody)),
+			ContentLength: int64(len(responseBody)),
+			Request:       request,
+		}, nil
+	}
+
+	// Send the real request and record it
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	response.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	exchange := recordedExchange{
+		Method:      request.Method,
+		Path:        request.URL.RequestURI(),
+		Request:     string(requestBody),
+		Status:      response.StatusCode,
+		ContentType: response.Header.Get("Content-Type"),
+		Response:    string(responseBody),
+	}
+	err = t.saveExchange(serviceDir, key, occurrence, exchange)
+	if err != nil {
+		return nil, fmt.Errorf("error recording %s %s: %w", request.Method, request.URL.RequestURI(), err)
+	}
+	return response, nil
+
+}
+
+// Get the key that identifies a request, ignoring its host and any JSON-RPC IDs
+func (t *trafficTransport) getRequestKey(request *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(request.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(request.URL.RequestURI()))
+	hash.Write([]byte{0})
+	hash.Write(normalizeJsonRpcRequest(body))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Get the path of a stored exchange
+func getExchangePath(serviceDir string, key string, occurrence int) string {
+	return filepath.Join(serviceDir, fmt.Sprintf("%s-%d.json", key, occurrence))
+}
+
+// Store an exchange
+func (t *trafficTransport) saveExchange(serviceDir string, key string, occurrence int, exchange recordedExchange) error {
+	err := os.MkdirAll(serviceDir, 0755)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", serviceDir, err)
+	}
+	bytes, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing exchange: %w", err)
+	}
+	return os.WriteFile(getExchangePath(serviceDir, key, occurrence), bytes, 0644)
+}
+
+// Load a stored exchange. If a request is sent more often than it was while recording, the last recorded response is
+// served again.
+func (t *trafficTransport) loadExchange(serviceDir string, key string, occurrence int) (recordedExchange, error) {
+	for i := occurrence; i >= 0; i-- {
+		bytes, err := os.ReadFile(getExchangePath(serviceDir, key, i))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return recordedExchange{}, err
+		}
+		var exchange recordedExchange
+		err = json.Unmarshal(bytes, &exchange)
+		if err != nil {
+			return recordedExchange{}, fmt.Errorf("error deserializing %s: %w", getExchangePath(serviceDir, key, i), err)
+		}
+		return exchange, nil
+	}
+	return recordedExchange{}, fmt.Errorf("no response was recorded for this request in %s", serviceDir)
+}
+
+// Parse a JSON-RPC request or batch of requests into its individual messages, or nil if it isn't one
+func parseJsonRpcMessages(body []byte) []map[string]json.RawMessage {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil
+	}
+	var messages []map[string]json.RawMessage
+	if trimmed[0] == '[' {
+		if json.Unmarshal(trimmed, &messages) != nil {
+			return nil
+		}
+	} else {
+		var message map[string]json.RawMessage
+		if json.Unmarshal(trimmed, &message) != nil {
+			return nil
+		}
+		messages = []map[string]json.RawMessage{message}
+	}
+	for _, message := range messages {
+		if _, exists := message["jsonrpc"]; !exists {
+			return nil
+		}
+	}
+	return messages
+}
+
+// Remove the IDs from a JSON-RPC request, so requests that only differ by ID are matched; other bodies are unchanged
+func normalizeJsonRpcRequest(body []byte) []byte {
+	messages := parseJsonRpcMessages(body)
+	if messages == nil {
+		return body
+	}
+	for _, message := range messages {
+		delete(message, "id")
+	}
+	normalized, err := json.Marshal(messages)
+	if err != nil {
+		return body
+	}
+	return normalized
+}
+
+// Rewrite the IDs in a recorded JSON-RPC response to match the request being replayed
+func replaceJsonRpcIds(recordedRequest []byte, request []byte, response []byte) ([]byte, error) {
+
+	recordedMessages := parseJsonRpcMessages(recordedRequest)
+	messages := parseJsonRpcMessages(request)
+	if recordedMessages == nil || messages == nil {
+		return response, nil
+	}
+	if len(recordedMessages) != len(messages) {
+		return nil, fmt.Errorf("recorded batch has %d requests, but %d were sent", len(recordedMessages), len(messages))
+	}
+	ids := map[string]json.RawMessage{}
+	for i, message := range recordedMessages {
+		ids[string(message["id"])] = messages[i]["id"]
+	}
+
+	isBatch := bytes.HasPrefix(bytes.TrimSpace(response), []byte("["))
+	var responses []map[string]json.RawMessage
+	if isBatch {
+		err := json.Unmarshal(response, &responses)
+		if err != nil {
+			return nil, fmt.Errorf("error deserializing recorded response: %w", err)
+		}
+	} else {
+		var message map[string]json.RawMessage
+		err := json.Unmarshal(response, &message)
+		if err != nil {
+			return nil, fmt.Errorf("error deserializing recorded response: %w", err)
+		}
+		responses = []map[string]json.RawMessage{message}
+	}
+	for _, message := range responses {
+		id, exists := ids[string(message["id"])]
+		if exists {
+			message["id"] = id
+		}
+	}
+
+	if isBatch {
+		return json.Marshal(responses)
+	}
+	return json.Marshal(responses[0])
+
+}
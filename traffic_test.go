@@ -0,0 +1,304 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/fatih/color"
+	"github.com/rocket-pool/rocketpool-go/network"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/services/config"
+	cfgtypes "github.com/rocket-pool/smartnode/shared/types/config"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Create a transport that replays the traffic in a test directory
+func newReplayTransport(dir string) *trafficTransport {
+	return &trafficTransport{
+		mode:   trafficModeReplay,
+		dir:    dir,
+		next:   http.DefaultTransport,
+		lock:   &sync.Mutex{},
+		counts: map[string]int{},
+	}
+}
+
+func TestNormalizeJsonRpcRequest(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "single request",
+			body:     `{"jsonrpc":"2.0","id":7,"method":"eth_blockNumber","params":[]}`,
+			expected: `[{"jsonrpc":"2.0","method":"eth_blockNumber","params":[]}]`,
+		},
+		{
+			name:     "batch request",
+			body:     `[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},{"jsonrpc":"2.0","id":"two","method":"eth_blockNumber"}]`,
+			expected: `[{"jsonrpc":"2.0","method":"eth_chainId"},{"jsonrpc":"2.0","method":"eth_blockNumber"}]`,
+		},
+		{
+			name:     "not JSON-RPC",
+			body:     `{"ids":["1","2"]}`,
+			expected: `{"ids":["1","2"]}`,
+		},
+		{
+			name:     "empty body",
+			body:     ``,
+			expected: ``,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			normalized := string(normalizeJsonRpcRequest([]byte(test.body)))
+			if normalized != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, normalized)
+			}
+		})
+	}
+
+	// Requests that only differ by ID have to match
+	first := normalizeJsonRpcRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`))
+	second := normalizeJsonRpcRequest([]byte(`{"jsonrpc":"2.0","id":42,"method":"eth_chainId"}`))
+	if string(first) != string(second) {
+		t.Errorf("requests that only differ by ID were normalized to %s and %s", first, second)
+	}
+
+}
+
+func TestReplaceJsonRpcIds(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		recordedRequest string
+		request         string
+		response        string
+		expected        string
+	}{
+		{
+			name:            "single request",
+			recordedRequest: `{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`,
+			request:         `{"jsonrpc":"2.0","id":9,"method":"eth_chainId"}`,
+			response:        `{"jsonrpc":"2.0","id":1,"result":"0x1"}`,
+			expected:        `{"id":9,"jsonrpc":"2.0","result":"0x1"}`,
+		},
+		{
+			name:            "batch request answered out of order",
+			recordedRequest: `[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},{"jsonrpc":"2.0","id":2,"method":"eth_blockNumber"}]`,
+			request:         `[{"jsonrpc":"2.0","id":5,"method":"eth_chainId"},{"jsonrpc":"2.0","id":6,"method":"eth_blockNumber"}]`,
+			response:        `[{"jsonrpc":"2.0","id":2,"result":"0x101dfa0"},{"jsonrpc":"2.0","id":1,"result":"0x1"}]`,
+			expected:        `[{"id":6,"jsonrpc":"2.0","result":"0x101dfa0"},{"id":5,"jsonrpc":"2.0","result":"0x1"}]`,
+		},
+		{
+			name:            "not JSON-RPC",
+			recordedRequest: ``,
+			request:         ``,
+			response:        `{"data":{"slot":"6100000"}}`,
+			expected:        `{"data":{"slot":"6100000"}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := replaceJsonRpcIds([]byte(test.recordedRequest), []byte(test.request), []byte(test.response))
+			if err != nil {
+				t.Fatalf("error replacing IDs: %s", err)
+			}
+			if string(response) != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, response)
+			}
+		})
+	}
+
+	// Batches of different sizes can't be matched up
+	_, err := replaceJsonRpcIds(
+		[]byte(`[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}]`),
+		[]byte(`[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},{"jsonrpc":"2.0","id":2,"method":"eth_chainId"}]`),
+		[]byte(`[{"jsonrpc":"2.0","id":1,"result":"0x1"}]`),
+	)
+	if err == nil {
+		t.Error("expected an error for batches of different sizes")
+	}
+
+}
+
+// Replays the EC traffic of a price duty at mainnet block 16900000, recorded from a stub EC serving a TWAP pool whose
+// average tick over the interval was 37897
+func TestReplayRplTwap(t *testing.T) {
+
+	cfg := config.NewRocketPoolConfig("", true)
+	cfg.Smartnode.Network.Value = cfgtypes.Network_Mainnet
+	traffic := newReplayTransport("testdata/traffic/rpl-twap")
+	ec, err := dialRecordedEc(trafficReplayUrl, traffic.forService("ec"))
+	if err != nil {
+		t.Fatalf("error connecting to the EC: %s", err)
+	}
+	rp, err := rocketpool.NewRocketPool(ec, common.HexToAddress(cfg.Smartnode.GetStorageAddress()))
+	if err != nil {
+		t.Fatalf("error creating Rocket Pool wrapper: %s", err)
+	}
+	task := &submitRplPrice{
+		log:    log.NewColorLogger(color.FgHiWhite),
+		errLog: log.NewColorLogger(color.FgRed),
+		cfg:    cfg,
+		ec:     ec,
+		rp:     rp,
+	}
+
+	// Repeated requests are replayed too, even though their JSON-RPC IDs differ
+	for i := 0; i < 2; i++ {
+		price, err := task.getRplTwap(16900000)
+		if err != nil {
+			t.Fatalf("error getting RPL price: %s", err)
+		}
+		if price.String() != "22606664481085169" {
+			t.Errorf("expected price 22606664481085169, got %s", price)
+		}
+	}
+
+	// Blocks that weren't recorded fail instead of being fetched
+	_, err = task.getRplTwap(16900001)
+	if err == nil {
+		t.Error("expected an error for a block without recorded traffic")
+	}
+
+}
+
+func TestTrafficTransportIgnoresOtherHosts(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("live"))
+	}))
+	defer server.Close()
+
+	// Nothing was recorded, so only requests to the BN's host can fail
+	traffic := newReplayTransport(t.TempDir())
+	bnTransport, err := traffic.forServiceUrl("bn", "http://bn.invalid:5052")
+	if err != nil {
+		t.Fatalf("error creating BN transport: %s", err)
+	}
+	client := &http.Client{Transport: bnTransport}
+
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request to another host was intercepted: %s", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if string(body) != "live" {
+		t.Errorf("expected the live response, got %s", body)
+	}
+
+	_, err = client.Get("http://bn.invalid:5052/eth/v1/node/version")
+	if err == nil {
+		t.Error("expected an error replaying an unrecorded BN request")
+	}
+
+}
+
+// The mainnet block whose last reported prices and balances TestReplayNetworkBalances checks
+const networkBalancesTargetBlock uint64 = 16900000
+
+// Replays the EC and BN traffic of the price and balance duties for the prices and balances reported on mainnet as of
+// networkBalancesTargetBlock, and checks the simulated values match the reported ones exactly. Setting
+// ODAOTOOL_TEST_EC_ENDPOINT and ODAOTOOL_TEST_BN_ENDPOINT to an archive EC and BN records the traffic instead.
+func TestReplayNetworkBalances(t *testing.T) {
+
+	dir := "testdata/traffic/network-balances"
+	ecUrl := os.Getenv("ODAOTOOL_TEST_EC_ENDPOINT")
+	bnUrl := os.Getenv("ODAOTOOL_TEST_BN_ENDPOINT")
+	var traffic *trafficTransport
+	if ecUrl != "" && bnUrl != "" {
+		traffic = newReplayTransport(dir)
+		traffic.mode = trafficModeRecord
+	} else {
+		_, err := os.Stat(dir)
+		if os.IsNotExist(err) {
+			t.Skipf("%s hasn't been recorded; set ODAOTOOL_TEST_EC_ENDPOINT and ODAOTOOL_TEST_BN_ENDPOINT to an archive EC and BN to record it", dir)
+		}
+		traffic = newReplayTransport(dir)
+		ecUrl = trafficReplayUrl
+		bnUrl = trafficReplayUrl
+	}
+
+	// Recording and replaying the BN replaces the default client's transport, so put it back afterwards
+	defaultTransport := http.DefaultClient.Transport
+	t.Cleanup(func() {
+		http.DefaultClient.Transport = defaultTransport
+	})
+
+	logger := log.NewColorLogger(color.FgHiWhite)
+	errorLogger := log.NewColorLogger(color.FgRed)
+	c := cli.NewContext(&cli.App{}, flag.NewFlagSet("odaotool", flag.ContinueOnError), nil)
+	ec, bc, rp, cfg, mgr, err := initializeForEndpoints(c, logger, ecUrl, bnUrl, traffic)
+	if err != nil {
+		t.Fatalf("error initializing: %s", err)
+	}
+	price, balances := newDutyTasks(c, logger, errorLogger, ec, bc, rp, cfg, mgr)
+	opts := &bind.CallOpts{
+		BlockNumber: big.NewInt(0).SetUint64(networkBalancesTargetBlock),
+	}
+
+	// Prices
+	pricesBlock, err := network.GetPricesBlock(rp, opts)
+	if err != nil {
+		t.Fatalf("error getting reported prices block: %s", err)
+	}
+	reportedPrice, err := network.GetRPLPrice(rp, opts)
+	if err != nil {
+		t.Fatalf("error getting reported RPL price: %s", err)
+	}
+	rplPrice, err := price.getRplTwap(pricesBlock)
+	if err != nil {
+		t.Fatalf("error getting RPL price for block %d: %s", pricesBlock, err)
+	}
+	if rplPrice.Cmp(reportedPrice) != 0 {
+		t.Errorf("expected RPL price %s for block %d, got %s", reportedPrice, pricesBlock, rplPrice)
+	}
+
+	// Balances
+	balancesBlock, err := network.GetBalancesBlock(rp, opts)
+	if err != nil {
+		t.Fatalf("error getting reported balances block: %s", err)
+	}
+	totalEth, err := network.GetTotalETHBalance(rp, opts)
+	if err != nil {
+		t.Fatalf("error getting reported total ETH: %s", err)
+	}
+	stakingEth, err := network.GetStakingETHBalance(rp, opts)
+	if err != nil {
+		t.Fatalf("error getting reported staking ETH: %s", err)
+	}
+	rethSupply, err := network.GetTotalRETHSupply(rp, opts)
+	if err != nil {
+		t.Fatalf("error getting reported rETH supply: %s", err)
+	}
+	networkBalances, _, err := balances.getNetworkBalancesForBlock(balancesBlock)
+	if err != nil {
+		t.Fatalf("error getting network balances for block %d: %s", balancesBlock, err)
+	}
+	if networkBalances.getTotalEth().Cmp(totalEth) != 0 {
+		t.Errorf("expected total ETH %s for block %d, got %s", totalEth, balancesBlock, networkBalances.getTotalEth())
+	}
+	if networkBalances.MinipoolsStaking.Cmp(stakingEth) != 0 {
+		t.Errorf("expected staking ETH %s for block %d, got %s", stakingEth, balancesBlock, networkBalances.MinipoolsStaking)
+	}
+	if networkBalances.RETHSupply.Cmp(rethSupply) != 0 {
+		t.Errorf("expected rETH supply %s for block %d, got %s", rethSupply, balancesBlock, networkBalances.RETHSupply)
+	}
+
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/rocket-pool/rocketpool-go/network"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Verify network balances task
+type verifyNetworkBalances struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	rp           *rocketpool.RocketPool
+	balances     *submitNetworkBalances
+	tolerance    float64
+	outputFormat string
+}
+
+// Comparison of an on-chain value with its simulated counterpart
+type valueDeviation struct {
+	Name      string  `json:"name"`
+	OnChain   string  `json:"onChain"`
+	Simulated string  `json:"simulated"`
+	Delta     string  `json:"delta"`
+	Relative  float64 `json:"relative"`
+	Exceeded  bool    `json:"exceeded"`
+}
+
+// Machine-readable network balance verification report
+type verifyNetworkBalancesOutput struct {
+	ReportedBlock uint64           `json:"reportedBlock"`
+	BeaconSlot    uint64           `json:"beaconSlot"`
+	Tolerance     float64          `json:"tolerance"`
+	Deviations    []valueDeviation `json:"deviations"`
+	Passed        bool             `json:"passed"`
+}
+
+// Create verify network balances task
+func newVerifyNetworkBalances(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*verifyNetworkBalances, error) {
+
+	tolerance := c.Float64("tolerance")
+	if tolerance < 0 {
+		return nil, fmt.Errorf("tolerance must be non-negative")
+	}
+
+	balances, err := newSubmitNetworkBalances(c, logger, errorLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return task
+	return &verifyNetworkBalances{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		rp:           balances.rp,
+		balances:     balances,
+		tolerance:    tolerance,
+		outputFormat: balances.outputFormat,
+	}, nil
+
+}
+
+// Verify the network balances reported on-chain
+func (t *verifyNetworkBalances) run() error {
+
+	// Read the reported balances as of the target block, or the chain head
+	opts := &bind.CallOpts{}
+	if t.c.IsSet("target-block") {
+		opts.BlockNumber = big.NewInt(0).SetUint64(t.c.Uint64("target-block"))
+	} else {
+		t.log.Printlnf("Target block not set, reading the balances reported as of the chain head.")
+	}
+
+	reportedBlock, err := network.GetBalancesBlock(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting reported balances block: %w", err)
+	}
+	if reportedBlock == 0 {
+		t.log.Println("Network balances have not been reported yet.")
+		return nil
+	}
+	totalEth, err := network.GetTotalETHBalance(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting reported total ETH balance: %w", err)
+	}
+	stakingEth, err := network.GetStakingETHBalance(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting reported staking ETH balance: %w", err)
+	}
+	rethSupply, err := network.GetTotalRETHSupply(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting reported rETH supply: %w", err)
+	}
+	t.log.Printlnf("Network balances were last reported for block %d.", reportedBlock)
+
+	// Simulate the balances at the reported block
+	t.log.Printlnf("Calculating network balances for block %d...", reportedBlock)
+	balances, slotNumber, err := t.balances.getNetworkBalancesForBlock(reportedBlock)
+	if err != nil {
+		return err
+	}
+
+	// Compare them
+	deviations := []valueDeviation{
+		getValueDeviation("Total ETH", totalEth, balances.getTotalEth(), t.tolerance),
+		getValueDeviation("Staking ETH", stakingEth, balances.MinipoolsStaking, t.tolerance),
+		getValueDeviation("rETH supply", rethSupply, balances.RETHSupply, t.tolerance),
+	}
+	passed := true
+	for _, deviation := range deviations {
+		logger := t.log
+		if deviation.Exceeded {
+			logger = t.errLog
+			passed = false
+		}
+		logger.Printlnf("%s: on-chain %s wei, simulated %s wei, delta %s wei (%.6f%%)", deviation.Name, deviation.OnChain, deviation.Simulated, deviation.Delta, deviation.Relative*100)
+	}
+
+	// Print the machine-readable report
+	if t.outputFormat == outputFormatJson {
+		err = printJson(verifyNetworkBalancesOutput{
+			ReportedBlock: reportedBlock,
+			BeaconSlot:    slotNumber,
+			Tolerance:     t.tolerance,
+			Deviations:    deviations,
+			Passed:        passed,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !passed {
+		return fmt.Errorf("simulated balances for block %d deviate from the reported balances by more than the tolerance of %.6f%%", reportedBlock, t.tolerance*100)
+	}
+	t.log.Println("Reported balances match the simulation.")
+	return nil
+
+}
+
+// Compare an on-chain value with its simulated counterpart. The relative deviation is taken against the on-chain value;
+// if that is zero, any difference counts as a 100% deviation.
+func getValueDeviation(name string, onChain *big.Int, simulated *big.Int, tolerance float64) valueDeviation {
+	delta := big.NewInt(0).Sub(simulated, onChain)
+
+	var relative float64
+	if onChain.Sign() != 0 {
+		relative, _ = big.NewFloat(0).Quo(new(big.Float).SetInt(delta), new(big.Float).SetInt(onChain)).Float64()
+	} else if delta.Sign() != 0 {
+		relative = float64(delta.Sign())
+	}
+
+	return valueDeviation{
+		Name:      name,
+		OnChain:   onChain.String(),
+		Simulated: simulated.String(),
+		Delta:     delta.String(),
+		Relative:  relative,
+		Exceeded:  math.Abs(relative) > tolerance || (tolerance == 0 && delta.Sign() != 0),
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rocket-pool/rocketpool-go/rewards"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/urfave/cli/v2"
+	"github.com/wealdtech/go-merkletree"
+	"github.com/wealdtech/go-merkletree/keccak256"
+
+	rprewards "github.com/rocket-pool/smartnode/shared/services/rewards"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Verify rewards tree task
+type verifyRewardsTree struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	rp           *rocketpool.RocketPool
+	generator    *generateRewardsTree
+	outputFormat string
+}
+
+// The difference between a node's rewards in the provided file and in a regenerated tree
+type nodeRewardsDelta struct {
+	Address               common.Address `json:"address"`
+	FileNetwork           uint64         `json:"fileNetwork"`
+	GeneratedNetwork      uint64         `json:"generatedNetwork"`
+	FileRpl               string         `json:"fileRpl"`
+	GeneratedRpl          string         `json:"generatedRpl"`
+	FileSmoothingEth      string         `json:"fileSmoothingEth"`
+	GeneratedSmoothingEth string         `json:"generatedSmoothingEth"`
+}
+
+// Machine-readable rewards tree verification report
+type verifyRewardsTreeOutput struct {
+	Interval          uint64             `json:"interval"`
+	File              string             `json:"file"`
+	FileMerkleRoot    string             `json:"fileMerkleRoot"`
+	ComputedRoot      string             `json:"computedMerkleRoot"`
+	OnChainMerkleRoot string             `json:"onChainMerkleRoot"`
+	Matches           bool               `json:"matches"`
+	GeneratedRoot     string             `json:"generatedMerkleRoot,omitempty"`
+	NodeDeltas        []nodeRewardsDelta `json:"nodeDeltas,omitempty"`
+}
+
+// Create verify rewards tree task
+func newVerifyRewardsTree(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*verifyRewardsTree, error) {
+
+	if c.String("file") == "" {
+		return nil, fmt.Errorf("file must be provided")
+	}
+
+	generator, err := newGenerateRewardsTree(c, logger, errorLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return task
+	return &verifyRewardsTree{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		rp:           generator.rp,
+		generator:    generator,
+		outputFormat: generator.outputFormat,
+	}, nil
+
+}
+
+// Verify a rewards file against the canonical Merkle root
+func (t *verifyRewardsTree) run() error {
+
+	// Load the rewards file
+	path := t.c.String("file")
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading rewards file %s: %w", path, err)
+	}
+	rewardsFile := rprewards.RewardsFile{}
+	err = json.Unmarshal(bytes, &rewardsFile)
+	if err != nil {
+		return fmt.Errorf("error deserializing rewards file %s: %w", path, err)
+	}
+
+	// Make sure it's for the right interval
+	index := rewardsFile.Index
+	if t.c.IsSet("interval") && t.c.Uint64("interval") != index {
+		return fmt.Errorf("rewards file %s is for interval %d, not interval %d", path, index, t.c.Uint64("interval"))
+	}
+
+	// Recompute the Merkle root from the node rewards
+	computedRoot, err := getRewardsFileMerkleRoot(&rewardsFile)
+	if err != nil {
+		return err
+	}
+	t.log.Printlnf("Rewards file for interval %d lists a Merkle root of %s.", index, rewardsFile.MerkleRoot)
+	t.log.Printlnf("Merkle root computed from its node rewards: %s", computedRoot.Hex())
+	if common.HexToHash(rewardsFile.MerkleRoot) != computedRoot {
+		t.errLog.Println("WARNING: the rewards file's node rewards don't match the Merkle root it lists.")
+	}
+
+	// Get the canonical root
+	onChainRootBytes, err := rewards.MerkleRoots(t.rp, big.NewInt(0).SetUint64(index), nil)
+	if err != nil {
+		return fmt.Errorf("error getting the Merkle root for interval %d: %w", index, err)
+	}
+	onChainRoot := common.BytesToHash(onChainRootBytes)
+	if onChainRoot == (common.Hash{}) {
+		return fmt.Errorf("no Merkle root has been recorded on-chain for interval %d yet", index)
+	}
+	matches := (computedRoot == onChainRoot)
+	if matches {
+		t.log.Printlnf("This matches the Merkle root of %s recorded on-chain for interval %d.", onChainRoot.Hex(), index)
+	} else {
+		t.errLog.Printlnf("This does not match the Merkle root of %s recorded on-chain for interval %d.", onChainRoot.Hex(), index)
+	}
+
+	// Regenerate the tree and compare the node rewards if the roots disagree
+	output := verifyRewardsTreeOutput{
+		Interval:          index,
+		File:              path,
+		FileMerkleRoot:    rewardsFile.MerkleRoot,
+		ComputedRoot:      computedRoot.Hex(),
+		OnChainMerkleRoot: onChainRoot.Hex(),
+		Matches:           matches,
+	}
+	if !matches && t.c.Bool("regenerate") {
+		generatedFile, _, err := t.generator.generateTree(index)
+		if err != nil {
+			return err
+		}
+		generatedRoot := common.BytesToHash(generatedFile.MerkleTree.Root())
+		output.GeneratedRoot = generatedRoot.Hex()
+		t.log.Printlnf("Regenerated Merkle root: %s", generatedRoot.Hex())
+
+		output.NodeDeltas = getNodeRewardsDeltas(&rewardsFile, generatedFile)
+		for _, delta := range output.NodeDeltas {
+			t.errLog.Printlnf("Node %s: network %d vs. %d, RPL %s vs. %s wei, Smoothing Pool ETH %s vs. %s wei (file vs. regenerated)", delta.Address.Hex(), delta.FileNetwork, delta.GeneratedNetwork, delta.FileRpl, delta.GeneratedRpl, delta.FileSmoothingEth, delta.GeneratedSmoothingEth)
+		}
+		t.log.Printlnf("%d nodes have different rewards in the file and the regenerated tree.", len(output.NodeDeltas))
+	}
+
+	// Print the machine-readable report
+	if t.outputFormat == outputFormatJson {
+		err = printJson(output)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !matches {
+		return fmt.Errorf("rewards file %s does not match the Merkle root recorded on-chain for interval %d", path, index)
+	}
+	return nil
+
+}
+
+// Compute the Merkle root of a rewards file from its node rewards, the same way the tree generator does
+func getRewardsFileMerkleRoot(rewardsFile *rprewards.RewardsFile) (common.Hash, error) {
+
+	// Generate the leaf data for each node
+	totalData := make([][]byte, 0, len(rewardsFile.NodeRewards))
+	for address, rewardsForNode := range rewardsFile.NodeRewards {
+		// Ignore nodes that didn't receive any rewards
+		rplRewards := getNodeRplRewards(rewardsForNode)
+		ethRewards := getNodeEthRewards(rewardsForNode)
+		if rplRewards.Sign() == 0 && ethRewards.Sign() == 0 {
+			continue
+		}
+
+		// Node data is address[20] :: network[32] :: RPL[32] :: ETH[32]
+		nodeData := make([]byte, 0, 20+32*3)
+		nodeData = append(nodeData, address.Bytes()...)
+		nodeData = append(nodeData, big.NewInt(0).SetUint64(rewardsForNode.RewardNetwork).FillBytes(make([]byte, 32))...)
+		nodeData = append(nodeData, rplRewards.FillBytes(make([]byte, 32))...)
+		nodeData = append(nodeData, ethRewards.FillBytes(make([]byte, 32))...)
+		totalData = append(totalData, nodeData)
+	}
+	if len(totalData) == 0 {
+		return common.Hash{}, fmt.Errorf("rewards file doesn't have any nodes with rewards")
+	}
+
+	// Generate the tree
+	tree, err := merkletree.NewUsing(totalData, keccak256.New(), false, true)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("error generating Merkle tree: %w", err)
+	}
+	return common.BytesToHash(tree.Root()), nil
+
+}
+
+// Get the nodes whose rewards differ between two rewards files, sorted by address
+func getNodeRewardsDeltas(file *rprewards.RewardsFile, generated *rprewards.RewardsFile) []nodeRewardsDelta {
+
+	empty := &rprewards.NodeRewardsInfo{}
+	addresses := map[common.Address]bool{}
+	for address := range file.NodeRewards {
+		addresses[address] = true
+	}
+	for address := range generated.NodeRewards {
+		addresses[address] = true
+	}
+
+	deltas := []nodeRewardsDelta{}
+	for address := range addresses {
+		fileRewards, exists := file.NodeRewards[address]
+		if !exists {
+			fileRewards = empty
+		}
+		generatedRewards, exists := generated.NodeRewards[address]
+		if !exists {
+			generatedRewards = empty
+		}
+
+		fileRpl := getNodeRplRewards(fileRewards)
+		generatedRpl := getNodeRplRewards(generatedRewards)
+		fileEth := getNodeEthRewards(fileRewards)
+		generatedEth := getNodeEthRewards(generatedRewards)
+		if fileRewards.RewardNetwork == generatedRewards.RewardNetwork && fileRpl.Cmp(generatedRpl) == 0 && fileEth.Cmp(generatedEth) == 0 {
+			continue
+		}
+
+		deltas = append(deltas, nodeRewardsDelta{
+			Address:               address,
+			FileNetwork:           fileRewards.RewardNetwork,
+			GeneratedNetwork:      generatedRewards.RewardNetwork,
+			FileRpl:               fileRpl.String(),
+			GeneratedRpl:          generatedRpl.String(),
+			FileSmoothingEth:      fileEth.String(),
+			GeneratedSmoothingEth: generatedEth.String(),
+		})
+	}
+
+	sort.Slice(deltas, func(i int, j int) bool {
+		return deltas[i].Address.Hex() < deltas[j].Address.Hex()
+	})
+	return deltas
+
+}
+
+// Get the total RPL rewards for a node
+func getNodeRplRewards(rewardsForNode *rprewards.NodeRewardsInfo) *big.Int {
+	rplRewards := big.NewInt(0)
+	if rewardsForNode.CollateralRpl != nil {
+		rplRewards.Add(rplRewards, &rewardsForNode.CollateralRpl.Int)
+	}
+	if rewardsForNode.OracleDaoRpl != nil {
+		rplRewards.Add(rplRewards, &rewardsForNode.OracleDaoRpl.Int)
+	}
+	return rplRewards
+}
+
+// Get the Smoothing Pool ETH rewards for a node
+func getNodeEthRewards(rewardsForNode *rprewards.NodeRewardsInfo) *big.Int {
+	if rewardsForNode.SmoothingPoolEth == nil {
+		return big.NewInt(0)
+	}
+	return big.NewInt(0).Set(&rewardsForNode.SmoothingPoolEth.Int)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/rocket-pool/rocketpool-go/network"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+)
+
+// Verify RPL price task
+type verifyRplPrice struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	rp           *rocketpool.RocketPool
+	price        *submitRplPrice
+	tolerance    float64
+	outputFormat string
+}
+
+// Machine-readable RPL price verification report
+type verifyRplPriceOutput struct {
+	ReportedBlock uint64         `json:"reportedBlock"`
+	Tolerance     float64        `json:"tolerance"`
+	Deviation     valueDeviation `json:"deviation"`
+	DeviationBps  float64        `json:"deviationBps"`
+	Passed        bool           `json:"passed"`
+}
+
+// Create verify RPL price task
+func newVerifyRplPrice(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*verifyRplPrice, error) {
+
+	tolerance := c.Float64("tolerance")
+	if tolerance < 0 {
+		return nil, fmt.Errorf("tolerance must be non-negative")
+	}
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+
+	price, err := newSubmitRplPrice(c, logger, errorLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return task
+	return &verifyRplPrice{
+		c:            c,
+		log:          logger,
+		errLog:       errorLogger,
+		rp:           price.rp,
+		price:        price,
+		tolerance:    tolerance,
+		outputFormat: outputFormat,
+	}, nil
+
+}
+
+// Verify the RPL price reported on-chain
+func (t *verifyRplPrice) run() error {
+
+	// Read the reported price as of the target block, or the chain head
+	opts := &bind.CallOpts{}
+	if t.c.IsSet("target-block") {
+		opts.BlockNumber = big.NewInt(0).SetUint64(t.c.Uint64("target-block"))
+	} else {
+		t.log.Printlnf("Target block not set, reading the price reported as of the chain head.")
+	}
+
+	reportedBlock, err := network.GetPricesBlock(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting reported prices block: %w", err)
+	}
+	if reportedBlock == 0 {
+		t.log.Println("The RPL price has not been reported yet.")
+		return nil
+	}
+	reportedPrice, err := network.GetRPLPrice(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting reported RPL price: %w", err)
+	}
+	t.log.Printlnf("The RPL price was last reported for block %d.", reportedBlock)
+
+	// Recompute the TWAP at the reported block
+	t.log.Printlnf("Getting RPL price for block %d...", reportedBlock)
+	rplPrice, err := t.price.getRplTwap(reportedBlock)
+	if err != nil {
+		return err
+	}
+
+	// Compare them
+	deviation := getValueDeviation("RPL price", reportedPrice, rplPrice, t.tolerance)
+	deviationBps := deviation.Relative * 10000
+	logger := t.log
+	if deviation.Exceeded {
+		logger = t.errLog
+	}
+	logger.Printlnf("RPL price: on-chain %s wei, simulated %s wei, delta %s wei (%.2f bps)", deviation.OnChain, deviation.Simulated, deviation.Delta, deviationBps)
+
+	// Print the machine-readable report
+	if t.outputFormat == outputFormatJson {
+		err = printJson(verifyRplPriceOutput{
+			ReportedBlock: reportedBlock,
+			Tolerance:     t.tolerance,
+			Deviation:     deviation,
+			DeviationBps:  deviationBps,
+			Passed:        !deviation.Exceeded,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if deviation.Exceeded {
+		return fmt.Errorf("simulated RPL price for block %d deviates from the reported price by more than the tolerance of %.2f bps", reportedBlock, t.tolerance*10000)
+	}
+	t.log.Println("Reported RPL price matches the simulation.")
+	return nil
+
+}
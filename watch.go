@@ -0,0 +1,459 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/rocket-pool/rocketpool-go/network"
+	"github.com/rocket-pool/rocketpool-go/rocketpool"
+	"github.com/rocket-pool/rocketpool-go/settings/protocol"
+	"github.com/rocket-pool/rocketpool-go/utils/eth"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rocket-pool/smartnode/shared/services/beacon"
+	"github.com/rocket-pool/smartnode/shared/utils/log"
+	mathutils "github.com/rocket-pool/smartnode/shared/utils/math"
+)
+
+// Watch task
+type watch struct {
+	c            *cli.Context
+	log          log.ColorLogger
+	errLog       log.ColorLogger
+	rp           *rocketpool.RocketPool
+	bc           beacon.Client
+	price        *submitRplPrice
+	balances     *submitNetworkBalances
+	outputFormat string
+	pollInterval time.Duration
+	outputFile   *os.File
+	metrics      *dutyMetrics
+
+	everyFinalizedBlock       bool
+	lastPricesBlock           uint64
+	lastBalancesBlock         uint64
+	lastReportedPricesBlock   uint64
+	lastReportedBalancesBlock uint64
+	pricesDisabled            bool
+	balancesDisabled          bool
+	simulatedPrices           map[uint64]*big.Int
+	simulatedBalances         map[uint64]simulatedBalances
+}
+
+// Network balances simulated for a block, and the Beacon slot they were calculated at
+type simulatedBalances struct {
+	balances   networkBalances
+	slotNumber uint64
+}
+
+// The result of simulating a duty for a new reportable block
+type watchResult struct {
+	Duty            string                 `json:"duty"`
+	Time            time.Time              `json:"time"`
+	FinalizedSlot   uint64                 `json:"finalizedSlot"`
+	Block           uint64                 `json:"block"`
+	RplPrice        *rplPriceOutput        `json:"rplPrice,omitempty"`
+	NetworkBalances *networkBalancesOutput `json:"networkBalances,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+}
+
+// Create watch task
+func newWatch(c *cli.Context, logger log.ColorLogger, errorLogger log.ColorLogger) (*watch, error) {
+
+	outputFormat, err := getOutputFormat(c)
+	if err != nil {
+		return nil, err
+	}
+	pollInterval := c.Duration("poll-interval")
+	if pollInterval <= 0 {
+		return nil, fmt.Errorf("poll-interval must be positive")
+	}
+
+	ec, bc, rp, cfg, mgr, err := initialize(c, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing RP artifacts: %w", err)
+	}
+
+	price, balances := newDutyTasks(c, logger, errorLogger, ec, bc, rp, cfg, mgr)
+
+	// Return task
+	return &watch{
+		c:                   c,
+		log:                 logger,
+		errLog:              errorLogger,
+		rp:                  rp,
+		bc:                  bc,
+		price:               price,
+		balances:            balances,
+		outputFormat:        outputFormat,
+		pollInterval:        pollInterval,
+		everyFinalizedBlock: c.Bool("every-finalized-block"),
+		simulatedPrices:     map[uint64]*big.Int{},
+		simulatedBalances:   map[uint64]simulatedBalances{},
+	}, nil
+
+}
+
+// Watch for new reportable (or finalized) blocks until interrupted
+func (t *watch) run() error {
+
+	// Open the export file
+	if t.c.IsSet("output-file") {
+		path := t.c.String("output-file")
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("error opening %s: %w", path, err)
+		}
+		defer file.Close()
+		t.outputFile = file
+	}
+
+	// Start the metrics exporter
+	if t.c.IsSet("metrics-address") {
+		metrics, err := newDutyMetrics(t.c.String("metrics-address"), t.log, t.errLog)
+		if err != nil {
+			return err
+		}
+		defer metrics.close()
+		t.metrics = metrics
+	}
+
+	// Stop cleanly on SIGINT or SIGTERM. The signal is checked between duties, since loading the network state can't
+	// be interrupted, so a simulation in progress finishes first.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Restore the default signal handling after the first signal, so a second one kills the process right away
+	go func() {
+		<-ctx.Done()
+		stop()
+		t.log.Println("Stopping after the simulation in progress, press Ctrl+C again to exit immediately.")
+	}()
+
+	if t.everyFinalizedBlock {
+		t.log.Printlnf("Watching for new finalized blocks every %s, press Ctrl+C to stop after the simulation in progress.", t.pollInterval)
+	} else {
+		t.log.Printlnf("Watching for new reportable blocks every %s, press Ctrl+C to stop after the simulation in progress.", t.pollInterval)
+	}
+	ticker := time.NewTicker(t.pollInterval)
+	defer ticker.Stop()
+	for {
+		err := t.check(ctx)
+		if err != nil {
+			t.metrics.addError("watch")
+			t.errLog.Println(err.Error())
+			t.errLog.Println("*** Watch iteration failed, retrying at the next poll. ***")
+		}
+
+		select {
+		case <-ctx.Done():
+			t.log.Println("Shutting down.")
+			return nil
+		case <-ticker.C:
+		}
+	}
+
+}
+
+// Check the latest finalized block for new reportable blocks, and simulate the duties for them
+func (t *watch) check(ctx context.Context) error {
+
+	// Get the latest finalized block
+	finalized, exists, err := t.bc.GetBeaconBlock("finalized")
+	if err != nil {
+		return fmt.Errorf("error getting finalized beacon block: %w", err)
+	}
+	if !exists || !finalized.HasExecutionPayload {
+		return fmt.Errorf("finalized beacon block %d doesn't have an execution payload", finalized.Slot)
+	}
+	opts := &bind.CallOpts{
+		BlockNumber: big.NewInt(0).SetUint64(finalized.ExecutionBlockNumber),
+	}
+
+	// Get the blocks to simulate
+	var pricesBlock uint64
+	var balancesBlock uint64
+	if t.everyFinalizedBlock {
+		pricesBlock = finalized.ExecutionBlockNumber
+		balancesBlock = finalized.ExecutionBlockNumber
+	} else {
+		pricesBlockBig, err := network.GetLatestReportablePricesBlock(t.rp, opts)
+		if err != nil {
+			return fmt.Errorf("error getting latest reportable prices block: %w", err)
+		}
+		balancesBlockBig, err := network.GetLatestReportableBalancesBlock(t.rp, opts)
+		if err != nil {
+			return fmt.Errorf("error getting latest reportable balances block: %w", err)
+		}
+		pricesBlock = pricesBlockBig.Uint64()
+		balancesBlock = balancesBlockBig.Uint64()
+	}
+
+	// Check which duties are enabled
+	pricesEnabled, err := protocol.GetSubmitPricesEnabled(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error checking if price submissions are enabled: %w", err)
+	}
+	balancesEnabled, err := protocol.GetSubmitBalancesEnabled(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error checking if balance submissions are enabled: %w", err)
+	}
+	if !pricesEnabled && !t.pricesDisabled {
+		t.log.Printlnf("Price submissions are disabled as of finalized block %d, skipping the price duty until they're enabled.", finalized.ExecutionBlockNumber)
+	}
+	if !balancesEnabled && !t.balancesDisabled {
+		t.log.Printlnf("Balance submissions are disabled as of finalized block %d, skipping the balance duty until they're enabled.", finalized.ExecutionBlockNumber)
+	}
+	t.pricesDisabled = !pricesEnabled
+	t.balancesDisabled = !balancesEnabled
+
+	// Simulate the price duty
+	if pricesEnabled && pricesBlock > t.lastPricesBlock && ctx.Err() == nil {
+		t.log.Printlnf("New prices block %d (finalized slot %d), getting RPL price...", pricesBlock, finalized.Slot)
+		result := watchResult{
+			Duty:          "prices",
+			Time:          time.Now().UTC(),
+			FinalizedSlot: finalized.Slot,
+			Block:         pricesBlock,
+		}
+		rplPrice, err := t.getRplPrice(pricesBlock)
+		if err != nil {
+			result.Error = err.Error()
+			t.errLog.Printlnf("Error getting RPL price for block %d: %s", pricesBlock, err.Error())
+		} else {
+			result.RplPrice = &rplPriceOutput{
+				Block:    pricesBlock,
+				Price:    rplPrice.String(),
+				PriceEth: mathutils.RoundDown(eth.WeiToEth(rplPrice), 6),
+			}
+			t.log.Printlnf("RPL price at block %d: %.6f ETH", pricesBlock, result.RplPrice.PriceEth)
+			t.metrics.setRplPrice(result.RplPrice)
+			t.lastPricesBlock = pricesBlock
+			pruneSimulations(t.simulatedPrices, pricesBlock)
+		}
+		err = t.export(result)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Simulate the balances duty
+	if balancesEnabled && balancesBlock > t.lastBalancesBlock && ctx.Err() == nil {
+		t.log.Printlnf("New balances block %d (finalized slot %d), calculating network balances...", balancesBlock, finalized.Slot)
+		result := watchResult{
+			Duty:          "balances",
+			Time:          time.Now().UTC(),
+			FinalizedSlot: finalized.Slot,
+			Block:         balancesBlock,
+		}
+		balances, err := t.getNetworkBalances(balancesBlock)
+		if err != nil {
+			result.Error = err.Error()
+			t.errLog.Printlnf("Error calculating network balances for block %d: %s", balancesBlock, err.Error())
+		} else {
+			balancesOutput := t.balances.getNetworkBalancesOutput(balances.balances, balances.slotNumber)
+			result.NetworkBalances = &balancesOutput
+			t.log.Printlnf("Total ETH at block %d: %s wei, rETH supply: %s wei, ratio: %.6f", balancesBlock, balancesOutput.TotalEth, balancesOutput.RETHSupply, balancesOutput.RETHRatio)
+			t.metrics.setNetworkBalances(balances.balances)
+			t.lastBalancesBlock = balancesBlock
+			pruneSimulations(t.simulatedBalances, balancesBlock)
+		}
+		err = t.export(result)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Compare the simulations against the values the Oracle DAO reported on-chain
+	if t.metrics != nil && ctx.Err() == nil {
+		err = t.checkReportedPrice(opts)
+		if err != nil {
+			return err
+		}
+	}
+	if t.metrics != nil && ctx.Err() == nil {
+		err = t.checkReportedBalances(opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// Check if simulations should be kept so they can be compared with the values reported on-chain later. That only
+// happens when metrics are exported, and only for reportable blocks, since the Oracle DAO can only report for those.
+func (t *watch) isCachingSimulations() bool {
+	return t.metrics != nil && !t.everyFinalizedBlock
+}
+
+// Get the simulated RPL price for a block, reusing a previous simulation if there is one
+func (t *watch) getRplPrice(blockNumber uint64) (*big.Int, error) {
+
+	rplPrice, exists := t.simulatedPrices[blockNumber]
+	if exists {
+		return rplPrice, nil
+	}
+	rplPrice, err := t.price.getRplTwap(blockNumber)
+	if err != nil {
+		t.metrics.addError("prices")
+		return nil, err
+	}
+	if t.isCachingSimulations() {
+		t.simulatedPrices[blockNumber] = rplPrice
+	}
+	return rplPrice, nil
+
+}
+
+// Get the simulated network balances for a block, reusing a previous simulation if there is one
+func (t *watch) getNetworkBalances(blockNumber uint64) (simulatedBalances, error) {
+
+	balances, exists := t.simulatedBalances[blockNumber]
+	if exists {
+		return balances, nil
+	}
+	networkBalances, slotNumber, err := t.balances.getNetworkBalancesForBlock(blockNumber)
+	if err != nil {
+		t.metrics.addError("balances")
+		return simulatedBalances{}, err
+	}
+	balances = simulatedBalances{
+		balances:   networkBalances,
+		slotNumber: slotNumber,
+	}
+	if t.isCachingSimulations() {
+		// Only the totals are compared, so don't hold on to the per-minipool and per-node breakdowns
+		cached := balances
+		cached.balances.Minipools = nil
+		cached.balances.Nodes = nil
+		t.simulatedBalances[blockNumber] = cached
+	}
+	return balances, nil
+
+}
+
+// Compare the latest RPL price reported on-chain with the simulated price for its block
+func (t *watch) checkReportedPrice(opts *bind.CallOpts) error {
+
+	reportedBlock, err := network.GetPricesBlock(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting reported prices block: %w", err)
+	}
+	if reportedBlock <= t.lastReportedPricesBlock {
+		return nil
+	}
+	reportedPrice, err := network.GetRPLPrice(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting reported RPL price: %w", err)
+	}
+	simulatedPrice, err := t.getRplPrice(reportedBlock)
+	if err != nil {
+		return fmt.Errorf("error getting RPL price for reported block %d: %w", reportedBlock, err)
+	}
+
+	deviation := getValueDeviation("RPL price", reportedPrice, simulatedPrice, 0)
+	t.logDeviation(reportedBlock, deviation)
+	t.metrics.setDeviation("rpl_price", deviation)
+	t.lastReportedPricesBlock = reportedBlock
+
+	// Older simulations won't be compared anymore
+	pruneSimulations(t.simulatedPrices, reportedBlock)
+	return nil
+
+}
+
+// Compare the latest network balances reported on-chain with the simulated balances for their block
+func (t *watch) checkReportedBalances(opts *bind.CallOpts) error {
+
+	reportedBlock, err := network.GetBalancesBlock(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting reported balances block: %w", err)
+	}
+	if reportedBlock <= t.lastReportedBalancesBlock {
+		return nil
+	}
+	totalEth, err := network.GetTotalETHBalance(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting reported total ETH balance: %w", err)
+	}
+	stakingEth, err := network.GetStakingETHBalance(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting reported staking ETH balance: %w", err)
+	}
+	rethSupply, err := network.GetTotalRETHSupply(t.rp, opts)
+	if err != nil {
+		return fmt.Errorf("error getting reported rETH supply: %w", err)
+	}
+	simulated, err := t.getNetworkBalances(reportedBlock)
+	if err != nil {
+		return fmt.Errorf("error calculating network balances for reported block %d: %w", reportedBlock, err)
+	}
+
+	deviations := map[string]valueDeviation{
+		"total_eth":   getValueDeviation("Total ETH", totalEth, simulated.balances.getTotalEth(), 0),
+		"staking_eth": getValueDeviation("Staking ETH", stakingEth, simulated.balances.MinipoolsStaking, 0),
+		"reth_supply": getValueDeviation("rETH supply", rethSupply, simulated.balances.RETHSupply, 0),
+	}
+	for value, deviation := range deviations {
+		t.logDeviation(reportedBlock, deviation)
+		t.metrics.setDeviation(value, deviation)
+	}
+	t.lastReportedBalancesBlock = reportedBlock
+
+	// Older simulations won't be compared anymore
+	pruneSimulations(t.simulatedBalances, reportedBlock)
+	return nil
+
+}
+
+// Remove the simulations for blocks before a block. Once a newer block is reportable, the Oracle DAO reports for that
+// one instead, so older simulations won't be compared.
+func pruneSimulations[T any](simulations map[uint64]T, block uint64) {
+	for simulatedBlock := range simulations {
+		if simulatedBlock < block {
+			delete(simulations, simulatedBlock)
+		}
+	}
+}
+
+// Log the deviation of a simulated value from the one reported on-chain
+func (t *watch) logDeviation(reportedBlock uint64, deviation valueDeviation) {
+	logger := t.log
+	if deviation.Exceeded {
+		logger = t.errLog
+	}
+	logger.Printlnf("Reported %s for block %d: on-chain %s wei, simulated %s wei, delta %s wei (%.6f%%)", deviation.Name, reportedBlock, deviation.OnChain, deviation.Simulated, deviation.Delta, deviation.Relative*100)
+}
+
+// Print a result to stdout and append it to the export file, as requested
+func (t *watch) export(result watchResult) error {
+
+	if t.outputFormat == outputFormatJson {
+		err := printJson(result)
+		if err != nil {
+			return err
+		}
+	}
+
+	if t.outputFile != nil {
+		bytes, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("error serializing result: %w", err)
+		}
+		_, err = t.outputFile.Write(append(bytes, '\n'))
+		if err != nil {
+			return fmt.Errorf("error writing to %s: %w", t.outputFile.Name(), err)
+		}
+	}
+	return nil
+
+}